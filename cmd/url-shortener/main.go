@@ -7,12 +7,20 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
+	"github.com/zulerne/url-shortener/internal/analytics"
 	"github.com/zulerne/url-shortener/internal/config"
+	"github.com/zulerne/url-shortener/internal/domainlist"
+	"github.com/zulerne/url-shortener/internal/janitor"
+	"github.com/zulerne/url-shortener/internal/lib/alias"
 	"github.com/zulerne/url-shortener/internal/lib/logger"
 	"github.com/zulerne/url-shortener/internal/server"
 	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/server/middleware"
+	"github.com/zulerne/url-shortener/internal/storage/memory"
+	"github.com/zulerne/url-shortener/internal/storage/postgres"
 	"github.com/zulerne/url-shortener/internal/storage/sqlite"
 )
 
@@ -20,28 +28,99 @@ func main() {
 	cfg := config.MustLoad()
 
 	logger.SetupLogger(cfg.Env)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(ctx, cfg, os.Args[2:]); err != nil {
+			slog.Error("migration failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	slog.Info("Starting url-shortener", "env", cfg.Env)
 	slog.Debug("Debug messages are enabled")
 
-	storage, err := sqlite.New(cfg.StoragePath)
+	storage, err := newStorage(cfg)
 	if err != nil {
 		slog.Error("failed to initialize storage", "error", err)
 		os.Exit(1)
 	}
 
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
-	defer cancel()
+	aliases, err := newAliasGenerator(cfg)
+	if err != nil {
+		slog.Error("failed to initialize alias generator", "error", err)
+		os.Exit(1)
+	}
+
+	destinations, lists, err := newDestinationRules(cfg)
+	if err != nil {
+		slog.Error("failed to load domain lists", "error", err)
+		os.Exit(1)
+	}
+
+	var notYetAvailable []byte
+	if cfg.HttpConfig.NotYetAvailablePageFile != "" {
+		notYetAvailable, err = os.ReadFile(cfg.HttpConfig.NotYetAvailablePageFile)
+		if err != nil {
+			slog.Error("failed to load not yet available page", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	recorder := analytics.NewRecorder(storage)
+
+	// Timeout cancels the request context, so storage calls stop with the request
+	h := middleware.Chain(
+		handler.NewHandler(storage, cfg.AliasLength, cfg.HttpConfig.User, cfg.HttpConfig.Password,
+			handler.WithClickRecorder(recorder),
+			handler.WithAliasGenerator(aliases),
+			handler.WithDedup(cfg.DedupURLs),
+			handler.WithStripParams(cfg.StripParams),
+			handler.WithDestinationRules(destinations),
+			handler.WithPublicHosts(cfg.HttpConfig.PublicHosts),
+			handler.WithRedirectType(cfg.HttpConfig.RedirectType),
+			handler.WithPasswordThrottle(cfg.HttpConfig.PasswordMaxFailures, cfg.HttpConfig.PasswordFailureWindow),
+			handler.WithNotYetAvailablePage(notYetAvailable),
+			handler.WithAliasRules(handler.AliasRules{
+				Charset:         cfg.AliasCharset,
+				MinLength:       cfg.AliasMinLength,
+				MaxLength:       cfg.AliasMaxLength,
+				CaseInsensitive: cfg.AliasCase == config.AliasCaseInsensitive,
+				Reserved:        cfg.AliasReserved,
+			}),
+		),
+		middleware.Timeout(cfg.HttpConfig.Timeout),
+	)
 
 	srv := &server.Server{
 		HttpServer: &http.Server{
 			Addr:         cfg.HttpConfig.Address,
-			Handler:      handler.NewHandler(storage, cfg.AliasLength, cfg.HttpConfig.User, cfg.HttpConfig.Password),
+			Handler:      h,
 			ReadTimeout:  cfg.HttpConfig.Timeout,
 			WriteTimeout: cfg.HttpConfig.Timeout,
 			IdleTimeout:  cfg.HttpConfig.IdleTimeout,
 		},
 		ShutdownTimeout: cfg.HttpConfig.Timeout,
 	}
+
+	// Background workers outlive the server, so clicks of in-flight requests are still flushed
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Go(func() {
+		janitor.New(storage, cfg.JanitorInterval).Run(workersCtx)
+	})
+	workers.Go(func() {
+		recorder.Run(workersCtx)
+	})
+	for _, list := range lists {
+		workers.Go(func() {
+			list.Run(workersCtx, cfg.DomainListReloadInterval)
+		})
+	}
+
 	// todo: Maybe remove blocking operation
 
 	if err = srv.Listen(ctx); err != nil && !errors.Is(err, context.DeadlineExceeded) {
@@ -49,5 +128,78 @@ func main() {
 		os.Exit(1)
 	}
 
+	stopWorkers()
+	workers.Wait()
+
 	slog.Info("Server stopped gracefully")
 }
+
+// appStorage is implemented by every storage backend: it serves
+// the http handlers and the background workers.
+type appStorage interface {
+	handler.Storage
+	janitor.Purger
+	analytics.Saver
+}
+
+// newStorage opens the storage backend selected by cfg.StorageDriver.
+func newStorage(cfg *config.Config) (appStorage, error) {
+	switch cfg.StorageDriver {
+	case config.StoragePostgres:
+		slog.Info("Using postgres storage")
+		return postgres.New(cfg.StorageDSN)
+	case config.StorageMemory:
+		slog.Warn("Using in-memory storage, data will be lost on restart")
+		return memory.New(), nil
+	default:
+		slog.Info("Using sqlite storage", "path", cfg.StoragePath)
+		return sqlite.New(cfg.StoragePath)
+	}
+}
+
+// newDestinationRules loads the domain lists configured in cfg. The lists are returned
+// too, so that they can be reloaded in the background.
+func newDestinationRules(cfg *config.Config) (handler.DestinationRules, []*domainlist.List, error) {
+	rules := handler.DestinationRules{Schemes: cfg.AllowedSchemes}
+	var lists []*domainlist.List
+
+	if cfg.DomainBlocklistFile != "" {
+		list, err := domainlist.Load(cfg.DomainBlocklistFile)
+		if err != nil {
+			return handler.DestinationRules{}, nil, err
+		}
+		rules.Blocked = list
+		lists = append(lists, list)
+	}
+
+	if cfg.DomainAllowlistFile != "" {
+		list, err := domainlist.Load(cfg.DomainAllowlistFile)
+		if err != nil {
+			return handler.DestinationRules{}, nil, err
+		}
+		rules.Allowed = list
+		lists = append(lists, list)
+	}
+
+	return rules, lists, nil
+}
+
+// newAliasGenerator returns the alias generation strategy selected by cfg.AliasStrategy.
+func newAliasGenerator(cfg *config.Config) (handler.AliasGenerator, error) {
+	// Case insensitive aliases are folded to lower case, ids must be encoded without upper case
+	alphabet := alias.Base62
+	if cfg.AliasCase == config.AliasCaseInsensitive {
+		alphabet = alias.Base36
+	}
+
+	switch cfg.AliasStrategy {
+	case config.AliasStrategySequential:
+		return alias.Sequential{Alphabet: alphabet}, nil
+	case config.AliasStrategyHashids:
+		return alias.NewHashids(cfg.AliasSalt, alphabet)
+	case config.AliasStrategyWords:
+		return alias.Words{Count: cfg.AliasWordCount}, nil
+	default:
+		return alias.Random{Length: cfg.AliasLength}, nil
+	}
+}
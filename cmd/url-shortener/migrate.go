@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/zulerne/url-shortener/internal/config"
+	"github.com/zulerne/url-shortener/internal/storage/migrate"
+	"github.com/zulerne/url-shortener/internal/storage/postgres"
+	"github.com/zulerne/url-shortener/internal/storage/sqlite"
+)
+
+const migrateUsage = "usage: url-shortener migrate up|down|status"
+
+// runMigrate implements the `migrate up|down|status` subcommand.
+func runMigrate(ctx context.Context, cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	db, m, err := newMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		return m.Up(ctx)
+	case "down":
+		return m.Down(ctx)
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printMigrationStatus(statuses)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+func newMigrator(cfg *config.Config) (*sql.DB, *migrate.Migrator, error) {
+	var (
+		db  *sql.DB
+		m   *migrate.Migrator
+		err error
+	)
+
+	switch cfg.StorageDriver {
+	case config.StorageSQLite:
+		if db, err = sqlite.Open(cfg.StoragePath); err == nil {
+			m, err = sqlite.NewMigrator(db)
+		}
+	case config.StoragePostgres:
+		if db, err = postgres.Open(cfg.StorageDSN); err == nil {
+			m, err = postgres.NewMigrator(db)
+		}
+	default:
+		return nil, nil, fmt.Errorf("storage driver %q has no migrations", cfg.StorageDriver)
+	}
+
+	if err != nil {
+		if db != nil {
+			db.Close()
+		}
+		return nil, nil, err
+	}
+
+	return db, m, nil
+}
+
+func printMigrationStatus(statuses []migrate.Status) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+
+	for _, s := range statuses {
+		appliedAt := "pending"
+		if s.Applied {
+			appliedAt = s.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,126 @@
+// Package analytics records link clicks without blocking the redirect path.
+package analytics
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+// Saver persists a batch of clicks.
+type Saver interface {
+	SaveClicks(ctx context.Context, clicks []storage.Click) error
+}
+
+// Recorder buffers clicks in memory and writes them to a Saver in batches.
+// When the buffer is full new clicks are dropped rather than slowing down redirects.
+type Recorder struct {
+	saver         Saver
+	clicks        chan storage.Click
+	batchSize     int
+	flushInterval time.Duration
+	flushTimeout  time.Duration
+}
+
+// Option configures a Recorder.
+type Option func(*Recorder)
+
+// WithBufferSize sets how many clicks can wait to be written.
+func WithBufferSize(size int) Option {
+	return func(r *Recorder) {
+		r.clicks = make(chan storage.Click, size)
+	}
+}
+
+// WithBatchSize sets the maximum number of clicks written at once.
+func WithBatchSize(size int) Option {
+	return func(r *Recorder) {
+		r.batchSize = size
+	}
+}
+
+// WithFlushInterval sets how often a partial batch is written.
+func WithFlushInterval(interval time.Duration) Option {
+	return func(r *Recorder) {
+		r.flushInterval = interval
+	}
+}
+
+// NewRecorder creates a new Recorder with the given options.
+func NewRecorder(saver Saver, opts ...Option) *Recorder {
+	r := &Recorder{
+		saver:         saver,
+		clicks:        make(chan storage.Click, 4096),
+		batchSize:     256,
+		flushInterval: time.Second,
+		flushTimeout:  5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Record queues a click. It never blocks.
+func (r *Recorder) Record(click storage.Click) {
+	select {
+	case r.clicks <- click:
+	default:
+		slog.Warn("click buffer is full, click dropped", "alias", click.Alias)
+	}
+}
+
+// Run writes queued clicks until ctx is done, then flushes what is left.
+func (r *Recorder) Run(ctx context.Context) {
+	const op = "analytics.Recorder.Run"
+	log := slog.With("op", op)
+
+	log.Info("Click recorder started")
+	defer log.Info("Click recorder stopped")
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.Click, 0, r.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+
+		// Writes must survive ctx cancellation so that the final flush succeeds
+		flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.flushTimeout)
+		defer cancel()
+
+		if err := r.saver.SaveClicks(flushCtx, batch); err != nil {
+			log.Error("failed to save clicks", "error", err, "count", len(batch))
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case click := <-r.clicks:
+			batch = append(batch, click)
+			if len(batch) >= r.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case click := <-r.clicks:
+					batch = append(batch, click)
+					if len(batch) >= r.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package analytics_test
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/analytics"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+type fakeSaver struct {
+	mu      sync.Mutex
+	batches [][]storage.Click
+}
+
+func (s *fakeSaver) SaveClicks(_ context.Context, clicks []storage.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]storage.Click(nil), clicks...))
+	return nil
+}
+
+func (s *fakeSaver) saved() (batches int, clicks int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.batches {
+		clicks += len(b)
+	}
+	return len(s.batches), clicks
+}
+
+func TestRecorderBatches(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	saver := &fakeSaver{}
+	r := analytics.NewRecorder(saver,
+		analytics.WithBatchSize(10),
+		analytics.WithFlushInterval(time.Hour),
+	)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+
+	for range 25 {
+		r.Record(storage.Click{Alias: "alias"})
+	}
+
+	require.Eventually(t, func() bool {
+		batches, _ := saver.saved()
+		return batches == 2
+	}, time.Second, time.Millisecond)
+
+	// The remaining partial batch is flushed on shutdown
+	cancel()
+	<-done
+
+	batches, clicks := saver.saved()
+	require.Equal(t, 3, batches)
+	require.Equal(t, 25, clicks)
+}
+
+func TestRecorderFlushInterval(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	saver := &fakeSaver{}
+	r := analytics.NewRecorder(saver, analytics.WithFlushInterval(time.Millisecond))
+
+	go r.Run(t.Context())
+
+	r.Record(storage.Click{Alias: "alias"})
+
+	require.Eventually(t, func() bool {
+		_, clicks := saver.saved()
+		return clicks == 1
+	}, time.Second, time.Millisecond)
+}
+
+func TestRecorderDropsWhenFull(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	saver := &fakeSaver{}
+	r := analytics.NewRecorder(saver, analytics.WithBufferSize(2))
+
+	// Run is not started, so Record must not block once the buffer is full
+	for range 5 {
+		r.Record(storage.Click{Alias: "alias"})
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	r.Run(ctx)
+
+	_, clicks := saver.saved()
+	require.Equal(t, 2, clicks)
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"log"
+	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,11 +15,55 @@ const (
 	EnvProd  = "production"
 )
 
+const (
+	StorageSQLite   = "sqlite"
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+const (
+	AliasCaseSensitive   = "sensitive"
+	AliasCaseInsensitive = "insensitive"
+)
+
+const (
+	AliasStrategyRandom     = "random"
+	AliasStrategySequential = "sequential"
+	AliasStrategyHashids    = "hashids"
+	AliasStrategyWords      = "words"
+)
+
 type Config struct {
-	Env         string
-	StoragePath string
-	AliasLength int
-	HttpConfig  HttpConfig
+	Env           string
+	StorageDriver string
+	StoragePath   string
+	StorageDSN    string
+	AliasStrategy string
+	AliasLength   int
+	// AliasSalt is the secret of the hashids strategy.
+	AliasSalt string
+	// AliasWordCount is the number of words of the words strategy.
+	AliasWordCount int
+	// AliasCharset, AliasMinLength, AliasMaxLength and AliasReserved restrict custom aliases.
+	AliasCharset   string
+	AliasMinLength int
+	AliasMaxLength int
+	AliasReserved  []string
+	// AliasCase is AliasCaseSensitive or AliasCaseInsensitive.
+	AliasCase string
+	// DedupURLs reuses the link of an already shortened destination instead of creating another one.
+	DedupURLs bool
+	// StripParams are query parameters removed from destinations, "utm_*" matches a prefix.
+	StripParams []string
+	// AllowedSchemes are the url schemes links may point to.
+	AllowedSchemes []string
+	// DomainBlocklistFile and DomainAllowlistFile hold one domain per line, "*.example.com"
+	// matches subdomains. They are reloaded every DomainListReloadInterval when changed.
+	DomainBlocklistFile      string
+	DomainAllowlistFile      string
+	DomainListReloadInterval time.Duration
+	JanitorInterval          time.Duration
+	HttpConfig               HttpConfig
 }
 
 type HttpConfig struct {
@@ -27,23 +73,111 @@ type HttpConfig struct {
 	ShutdownTimeout time.Duration
 	User            string
 	Password        string
+	// PublicHosts are the hosts short links are served at, Address by default.
+	PublicHosts []string
+	// RedirectType is the http status of redirects of links created without one.
+	RedirectType int
+	// PasswordMaxFailures wrong passwords per PasswordFailureWindow lock a protected link.
+	PasswordMaxFailures   int
+	PasswordFailureWindow time.Duration
+	// NotYetAvailablePageFile is an HTML page served for links before their valid_from.
+	// They are answered like missing links if it is empty.
+	NotYetAvailablePageFile string
 }
 
 func MustLoad() *Config {
 	cfg := &Config{
-		Env:         fetchString("ENV", "local"),
-		StoragePath: fetchStringRequired("STORAGE_PATH"),
-		AliasLength: fetchInt("ALIAS_LENGTH", 6),
+		Env:                      fetchString("ENV", "local"),
+		StorageDriver:            fetchString("STORAGE_DRIVER", StorageSQLite),
+		AliasStrategy:            fetchString("ALIAS_STRATEGY", AliasStrategyRandom),
+		AliasLength:              fetchInt("ALIAS_LENGTH", 6),
+		AliasWordCount:           fetchInt("ALIAS_WORD_COUNT", 3),
+		AliasCharset:             fetchString("ALIAS_CHARSET", "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"),
+		AliasMinLength:           fetchInt("ALIAS_MIN_LENGTH", 1),
+		AliasMaxLength:           fetchInt("ALIAS_MAX_LENGTH", 64),
+		AliasReserved:            fetchList("ALIAS_RESERVED"),
+		AliasCase:                fetchString("ALIAS_CASE", AliasCaseSensitive),
+		DedupURLs:                fetchBool("DEDUP_URLS", false),
+		StripParams:              fetchList("STRIP_QUERY_PARAMS"),
+		AllowedSchemes:           fetchList("ALLOWED_SCHEMES"),
+		DomainBlocklistFile:      fetchString("DOMAIN_BLOCKLIST_FILE", ""),
+		DomainAllowlistFile:      fetchString("DOMAIN_ALLOWLIST_FILE", ""),
+		DomainListReloadInterval: fetchDuration("DOMAIN_LIST_RELOAD_INTERVAL", 10*time.Second),
+		JanitorInterval:          fetchDuration("JANITOR_INTERVAL", time.Minute),
 		HttpConfig: HttpConfig{
-			Address:         fetchStringRequired("HTTP_ADDRESS"),
-			Timeout:         fetchDuration("HTTP_TIMEOUT", 5*time.Second),
-			IdleTimeout:     fetchDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
-			ShutdownTimeout: fetchDuration("HTTP_SHUTDOWN_TIMEOUT", 5*time.Second),
-			User:            fetchString("HTTP_USER", ""),
-			Password:        fetchString("HTTP_PASSWORD", ""),
+			Address:                 fetchStringRequired("HTTP_ADDRESS"),
+			Timeout:                 fetchDuration("HTTP_TIMEOUT", 5*time.Second),
+			IdleTimeout:             fetchDuration("HTTP_IDLE_TIMEOUT", 60*time.Second),
+			ShutdownTimeout:         fetchDuration("HTTP_SHUTDOWN_TIMEOUT", 5*time.Second),
+			User:                    fetchString("HTTP_USER", ""),
+			Password:                fetchString("HTTP_PASSWORD", ""),
+			PublicHosts:             fetchList("PUBLIC_HOSTS"),
+			RedirectType:            fetchInt("REDIRECT_TYPE", http.StatusTemporaryRedirect),
+			PasswordMaxFailures:     fetchInt("PASSWORD_MAX_FAILURES", 5),
+			PasswordFailureWindow:   fetchDuration("PASSWORD_FAILURE_WINDOW", 15*time.Minute),
+			NotYetAvailablePageFile: fetchString("NOT_YET_AVAILABLE_PAGE_FILE", ""),
 		},
 	}
 
+	switch cfg.StorageDriver {
+	case StorageSQLite:
+		cfg.StoragePath = fetchStringRequired("STORAGE_PATH")
+	case StoragePostgres:
+		cfg.StorageDSN = fetchStringRequired("STORAGE_DSN")
+	case StorageMemory:
+	default:
+		log.Fatalf("STORAGE_DRIVER %q is not supported", cfg.StorageDriver)
+	}
+
+	switch cfg.AliasStrategy {
+	case AliasStrategyHashids:
+		cfg.AliasSalt = fetchStringRequired("ALIAS_SALT")
+	case AliasStrategyRandom, AliasStrategySequential, AliasStrategyWords:
+	default:
+		log.Fatalf("ALIAS_STRATEGY %q is not supported", cfg.AliasStrategy)
+	}
+
+	if cfg.AliasLength < 1 || cfg.AliasWordCount < 1 {
+		log.Fatalf("ALIAS_LENGTH and ALIAS_WORD_COUNT must be at least 1")
+	}
+
+	if cfg.AliasMinLength < 1 || cfg.AliasMaxLength < cfg.AliasMinLength {
+		log.Fatalf("ALIAS_MIN_LENGTH and ALIAS_MAX_LENGTH must satisfy 1 <= min <= max")
+	}
+
+	if cfg.AliasCase != AliasCaseSensitive && cfg.AliasCase != AliasCaseInsensitive {
+		log.Fatalf("ALIAS_CASE %q is not supported", cfg.AliasCase)
+	}
+
+	switch cfg.HttpConfig.RedirectType {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		log.Fatalf("REDIRECT_TYPE %d is not supported, use 301, 302, 307 or 308", cfg.HttpConfig.RedirectType)
+	}
+
+	if cfg.HttpConfig.PasswordMaxFailures < 1 || cfg.HttpConfig.PasswordFailureWindow <= 0 {
+		log.Fatalf("PASSWORD_MAX_FAILURES and PASSWORD_FAILURE_WINDOW must be positive")
+	}
+
+	if cfg.DomainListReloadInterval <= 0 {
+		log.Fatalf("DOMAIN_LIST_RELOAD_INTERVAL must be positive")
+	}
+
+	if cfg.JanitorInterval <= 0 {
+		log.Fatalf("JANITOR_INTERVAL must be positive")
+	}
+
+	if len(cfg.HttpConfig.PublicHosts) == 0 {
+		cfg.HttpConfig.PublicHosts = []string{cfg.HttpConfig.Address}
+	}
+
+	if len(cfg.AllowedSchemes) == 0 {
+		cfg.AllowedSchemes = []string{"http", "https"}
+	}
+	for i, scheme := range cfg.AllowedSchemes {
+		cfg.AllowedSchemes[i] = strings.ToLower(scheme)
+	}
+
 	return cfg
 }
 
@@ -62,6 +196,17 @@ func fetchStringRequired(key string) string {
 	return val
 }
 
+// fetchList splits a comma separated list, skipping empty items.
+func fetchList(key string) []string {
+	var list []string
+	for item := range strings.SplitSeq(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func fetchDuration(key string, def time.Duration) time.Duration {
 	val, exists := os.LookupEnv(key)
 	if !exists || val == "" {
@@ -85,3 +230,15 @@ func fetchInt(key string, def int) int {
 	}
 	return i
 }
+
+func fetchBool(key string, def bool) bool {
+	val, exists := os.LookupEnv(key)
+	if !exists || val == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		log.Fatalf("%s is not a valid boolean", key)
+	}
+	return b
+}
@@ -0,0 +1,158 @@
+// Package domainlist matches hosts against a list of domains kept in a file,
+// reloading the list whenever the file changes.
+package domainlist
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// List is a set of domains loaded from a file. A line holds one domain,
+// "*.example.com" matches every subdomain of example.com but not example.com itself.
+// Empty lines and lines starting with "#" are ignored. List is safe for concurrent use.
+type List struct {
+	path    string
+	domains atomic.Pointer[domains]
+
+	// mu serializes reloads
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+type domains struct {
+	exact map[string]struct{}
+	// wildcard holds the parent domains of "*." entries
+	wildcard map[string]struct{}
+}
+
+// Load reads the list from path.
+func Load(path string) (*List, error) {
+	const op = "domainlist.Load"
+
+	l := &List{path: path}
+	if _, err := l.Reload(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return l, nil
+}
+
+// Match reports whether host is on the list. Hosts are compared case-insensitively,
+// without port and trailing dot.
+func (l *List) Match(host string) bool {
+	d := l.domains.Load()
+
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if _, ok := d.exact[host]; ok {
+		return true
+	}
+
+	for parent := host; ; {
+		_, parent, _ = strings.Cut(parent, ".")
+		if parent == "" {
+			return false
+		}
+		if _, ok := d.wildcard[parent]; ok {
+			return true
+		}
+	}
+}
+
+// Reload reads the file again if it changed since the last load and reports whether it did.
+// The current list is kept if the file cannot be read.
+func (l *List) Reload() (bool, error) {
+	const op = "domainlist.Reload"
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	if l.domains.Load() != nil && info.ModTime().Equal(l.modTime) {
+		return false, nil
+	}
+
+	d, err := parse(l.path)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	l.domains.Store(d)
+	l.modTime = info.ModTime()
+
+	return true, nil
+}
+
+// Run reloads the list every interval until ctx is done.
+func (l *List) Run(ctx context.Context, interval time.Duration) {
+	const op = "domainlist.Run"
+	log := slog.With("op", op, "path", l.path)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := l.Reload()
+			if err != nil {
+				log.Error("failed to reload domain list", "error", err)
+				continue
+			}
+			if reloaded {
+				log.Info("domain list reloaded")
+			}
+		}
+	}
+}
+
+func parse(path string) (*domains, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	d := &domains{
+		exact:    make(map[string]struct{}),
+		wildcard: make(map[string]struct{}),
+	}
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimSuffix(line, ".")
+		if parent, ok := strings.CutPrefix(line, "*."); ok {
+			if parent == "" || strings.Contains(parent, "*") {
+				return nil, fmt.Errorf("line %d: invalid domain %q", n, line)
+			}
+			d.wildcard[parent] = struct{}{}
+			continue
+		}
+		if strings.Contains(line, "*") {
+			return nil, fmt.Errorf("line %d: wildcards are only allowed as \"*.domain\"", n)
+		}
+
+		d.exact[line] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
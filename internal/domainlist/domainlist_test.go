@@ -0,0 +1,130 @@
+package domainlist_test
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/domainlist"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+)
+
+func writeList(t *testing.T, path string, content string, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	writeList(t, path, "# phishing\nEvil.com\n\n  *.bad.org  \nlocalhost.\n", time.Now())
+
+	list, err := domainlist.Load(path)
+	require.NoError(t, err)
+
+	cases := []struct {
+		host string
+		want bool
+	}{
+		{host: "evil.com", want: true},
+		{host: "EVIL.com.", want: true},
+		{host: "www.evil.com", want: false},
+		{host: "notevil.com", want: false},
+		{host: "bad.org", want: false},
+		{host: "www.bad.org", want: true},
+		{host: "a.b.bad.org", want: true},
+		{host: "bad.org.example", want: false},
+		{host: "localhost", want: true},
+		{host: "", want: false},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.want, list.Match(tc.host), tc.host)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	_, err := domainlist.Load(filepath.Join(dir, "missing.txt"))
+	require.Error(t, err)
+
+	for _, content := range []string{"*.\n", "ev*l.com\n", "*.*.com\n"} {
+		path := filepath.Join(dir, "invalid.txt")
+		writeList(t, path, content, time.Now())
+
+		_, err = domainlist.Load(path)
+		require.Error(t, err, content)
+	}
+}
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	modTime := time.Now().Add(-time.Hour)
+	writeList(t, path, "evil.com\n", modTime)
+
+	list, err := domainlist.Load(path)
+	require.NoError(t, err)
+
+	reloaded, err := list.Reload()
+	require.NoError(t, err)
+	require.False(t, reloaded, "unchanged file must not be reloaded")
+
+	writeList(t, path, "bad.org\n", modTime.Add(time.Minute))
+
+	reloaded, err = list.Reload()
+	require.NoError(t, err)
+	require.True(t, reloaded)
+	require.False(t, list.Match("evil.com"))
+	require.True(t, list.Match("bad.org"))
+
+	// A broken file keeps the current list
+	writeList(t, path, "ev*l.com\n", modTime.Add(2*time.Minute))
+
+	_, err = list.Reload()
+	require.Error(t, err)
+	require.True(t, list.Match("bad.org"))
+}
+
+func TestRun(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	modTime := time.Now().Add(-time.Hour)
+	writeList(t, path, "evil.com\n", modTime)
+
+	list, err := domainlist.Load(path)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		list.Run(ctx, time.Millisecond)
+		close(done)
+	}()
+
+	writeList(t, path, "bad.org\n", modTime.Add(time.Minute))
+
+	require.Eventually(t, func() bool {
+		return list.Match("bad.org")
+	}, time.Second, time.Millisecond)
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("list did not stop reloading after context cancellation")
+	}
+}
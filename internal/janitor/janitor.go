@@ -0,0 +1,53 @@
+// Package janitor periodically purges expired links from storage.
+package janitor
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Purger removes urls that expired at or before now.
+type Purger interface {
+	DeleteExpiredURLs(ctx context.Context, now time.Time) (int64, error)
+}
+
+type Janitor struct {
+	purger   Purger
+	interval time.Duration
+}
+
+func New(purger Purger, interval time.Duration) *Janitor {
+	return &Janitor{
+		purger:   purger,
+		interval: interval,
+	}
+}
+
+// Run purges expired urls every interval until ctx is done.
+func (j *Janitor) Run(ctx context.Context) {
+	const op = "janitor.Run"
+	log := slog.With("op", op)
+
+	log.Info("Janitor started", "interval", j.interval)
+	defer log.Info("Janitor stopped")
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			deleted, err := j.purger.DeleteExpiredURLs(ctx, now)
+			if err != nil {
+				log.Error("failed to delete expired urls", "error", err)
+				continue
+			}
+			if deleted > 0 {
+				log.Info("expired urls deleted", "count", deleted)
+			}
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package janitor_test
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/janitor"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+)
+
+type countingPurger struct {
+	calls atomic.Int64
+}
+
+func (p *countingPurger) DeleteExpiredURLs(_ context.Context, _ time.Time) (int64, error) {
+	p.calls.Add(1)
+	return 1, nil
+}
+
+func TestJanitorRun(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	purger := &countingPurger{}
+	ctx, cancel := context.WithCancel(t.Context())
+
+	done := make(chan struct{})
+	go func() {
+		janitor.New(purger, time.Millisecond).Run(ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return purger.calls.Load() >= 3
+	}, time.Second, time.Millisecond)
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not stop after context cancellation")
+	}
+}
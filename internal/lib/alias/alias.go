@@ -0,0 +1,78 @@
+// Package alias implements the strategies for minting aliases of links created without one.
+// Every strategy returns the alias of the link with row id on its attempt-th try,
+// attempt counting the aliases of that link which turned out to be taken.
+package alias
+
+import (
+	"math"
+	"strings"
+
+	"github.com/zulerne/url-shortener/internal/lib/random"
+)
+
+// Base62 is the alphabet of the base62 encoding.
+const Base62 = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Base36 is the lower case alphabet. Aliases folded to lower case must be encoded with it,
+// or different ids fold to the same alias.
+const Base36 = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// Random mints random base62 aliases. Every retry adds a character,
+// so a crowded alias space is escaped quickly.
+type Random struct {
+	Length int
+}
+
+func (g Random) Generate(_ int64, attempt int) string {
+	return random.Alias(g.Length + attempt)
+}
+
+// Sequential encodes the row id in Alphabet, Base62 if empty, giving the shortest
+// possible aliases. They are predictable, so links can be enumerated.
+type Sequential struct {
+	Alphabet string
+}
+
+func (g Sequential) Generate(id int64, _ int) string {
+	if g.Alphabet == "" {
+		return encode(uint64(id), Base62)
+	}
+	return encode(uint64(id), g.Alphabet)
+}
+
+// encode writes n in the positional system of alphabet.
+func encode(n uint64, alphabet string) string {
+	base := uint64(len(alphabet))
+
+	var buf [64]byte
+	i := len(buf)
+	for {
+		i--
+		buf[i] = alphabet[n%base]
+		n /= base
+		if n == 0 {
+			return string(buf[i:])
+		}
+	}
+}
+
+// decode is the inverse of encode. It reports false if s contains
+// characters outside of alphabet or does not fit in uint64.
+func decode(s string, alphabet string) (uint64, bool) {
+	if s == "" {
+		return 0, false
+	}
+
+	base := uint64(len(alphabet))
+
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		digit := strings.IndexByte(alphabet, s[i])
+		if digit < 0 || n > (math.MaxUint64-uint64(digit))/base {
+			return 0, false
+		}
+		n = n*base + uint64(digit)
+	}
+
+	return n, true
+}
@@ -0,0 +1,67 @@
+package alias_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/alias"
+)
+
+// requireAlphabet fails if s contains characters outside of alphabet.
+func requireAlphabet(t *testing.T, s string, alphabet string) {
+	t.Helper()
+
+	for _, c := range s {
+		require.True(t, strings.ContainsRune(alphabet, c), "unexpected character %q in %q", c, s)
+	}
+}
+
+func TestRandom(t *testing.T) {
+	g := alias.Random{Length: 6}
+
+	seen := make(map[string]struct{})
+	for range 1000 {
+		a := g.Generate(0, 0)
+		require.Len(t, a, 6)
+		requireAlphabet(t, a, alias.Base62)
+
+		_, exists := seen[a]
+		require.False(t, exists, "duplicate alias %q", a)
+		seen[a] = struct{}{}
+	}
+
+	require.Len(t, g.Generate(0, 2), 8, "retries must use longer aliases")
+}
+
+func TestSequential(t *testing.T) {
+	g := alias.Sequential{}
+
+	cases := []struct {
+		id   int64
+		want string
+	}{
+		{id: 0, want: "0"},
+		{id: 1, want: "1"},
+		{id: 61, want: "Z"},
+		{id: 62, want: "10"},
+		{id: 3843, want: "ZZ"},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.want, g.Generate(tc.id, 0))
+	}
+
+	lower := alias.Sequential{Alphabet: alias.Base36}
+	require.Equal(t, "a", lower.Generate(10, 0))
+	require.Equal(t, "10", lower.Generate(36, 0))
+
+	seen := make(map[string]struct{})
+	for id := int64(1); id <= 10000; id++ {
+		a := g.Generate(id, 0)
+		requireAlphabet(t, a, alias.Base62)
+
+		_, exists := seen[a]
+		require.False(t, exists, "duplicate alias %q for id %d", a, id)
+		seen[a] = struct{}{}
+	}
+}
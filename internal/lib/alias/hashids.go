@@ -0,0 +1,74 @@
+package alias
+
+import "errors"
+
+// Hashids obfuscates row ids the way hashids does: the id is encoded with an alphabet
+// shuffled by a secret salt and a per-id lottery character. Aliases are short and
+// can be decoded back to ids, but cannot be enumerated without the salt.
+type Hashids struct {
+	alphabet string
+	salt     string
+}
+
+// NewHashids returns a Hashids with the given salt, which must not be empty,
+// encoding with alphabet, like Base62 or Base36.
+func NewHashids(salt string, alphabet string) (*Hashids, error) {
+	if salt == "" {
+		return nil, errors.New("hashids salt must not be empty")
+	}
+	if len(alphabet) < 2 {
+		return nil, errors.New("hashids alphabet must have at least 2 characters")
+	}
+
+	return &Hashids{
+		alphabet: shuffle(alphabet, salt),
+		salt:     salt,
+	}, nil
+}
+
+func (g *Hashids) Generate(id int64, _ int) string {
+	n := uint64(id)
+	lottery := g.alphabet[n%uint64(len(g.alphabet))]
+
+	return string(lottery) + encode(n, g.idAlphabet(lottery))
+}
+
+// Decode returns the id an alias was generated from.
+// It reports false for aliases not generated with the same salt.
+func (g *Hashids) Decode(alias string) (int64, bool) {
+	if len(alias) < 2 {
+		return 0, false
+	}
+
+	n, ok := decode(alias[1:], g.idAlphabet(alias[0]))
+	if !ok || int64(n) < 0 {
+		return 0, false
+	}
+
+	// Re-encoding rejects a wrong lottery character and leading zeros
+	if g.Generate(int64(n), 0) != alias {
+		return 0, false
+	}
+
+	return int64(n), true
+}
+
+// idAlphabet is the alphabet the id is encoded with after the lottery character.
+func (g *Hashids) idAlphabet(lottery byte) string {
+	return shuffle(g.alphabet, (string(lottery) + g.salt + g.alphabet)[:len(g.alphabet)])
+}
+
+// shuffle is the consistent shuffle of hashids: the order of alphabet depends only on salt.
+func shuffle(alphabet string, salt string) string {
+	b := []byte(alphabet)
+
+	for i, v, p := len(b)-1, 0, 0; i > 0; i, v = i-1, v+1 {
+		v %= len(salt)
+		c := int(salt[v])
+		p += c
+		j := (c + v + p) % i
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return string(b)
+}
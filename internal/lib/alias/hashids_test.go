@@ -0,0 +1,88 @@
+package alias_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/alias"
+)
+
+func TestHashids(t *testing.T) {
+	g, err := alias.NewHashids("secret", alias.Base62)
+	require.NoError(t, err)
+
+	seen := make(map[string]struct{})
+	for id := int64(1); id <= 10000; id++ {
+		a := g.Generate(id, 0)
+		requireAlphabet(t, a, alias.Base62)
+
+		_, exists := seen[a]
+		require.False(t, exists, "duplicate alias %q for id %d", a, id)
+		seen[a] = struct{}{}
+
+		decoded, ok := g.Decode(a)
+		require.True(t, ok, "alias %q of id %d is not decodable", a, id)
+		require.Equal(t, id, decoded)
+	}
+
+	a := g.Generate(math.MaxInt64, 0)
+	decoded, ok := g.Decode(a)
+	require.True(t, ok)
+	require.Equal(t, int64(math.MaxInt64), decoded)
+}
+
+func TestHashidsBase36(t *testing.T) {
+	g, err := alias.NewHashids("secret", alias.Base36)
+	require.NoError(t, err)
+
+	seen := make(map[string]struct{})
+	for id := int64(1); id <= 10000; id++ {
+		a := g.Generate(id, 0)
+		requireAlphabet(t, a, alias.Base36)
+
+		_, exists := seen[a]
+		require.False(t, exists, "duplicate alias %q for id %d", a, id)
+		seen[a] = struct{}{}
+
+		decoded, ok := g.Decode(a)
+		require.True(t, ok, "alias %q of id %d is not decodable", a, id)
+		require.Equal(t, id, decoded)
+	}
+}
+
+func TestHashidsSalt(t *testing.T) {
+	g, err := alias.NewHashids("secret", alias.Base62)
+	require.NoError(t, err)
+
+	other, err := alias.NewHashids("another secret", alias.Base62)
+	require.NoError(t, err)
+
+	require.Equal(t, g.Generate(42, 0), g.Generate(42, 0), "aliases must be stable")
+	require.NotEqual(t, g.Generate(42, 0), other.Generate(42, 0), "aliases must depend on the salt")
+	require.NotEqual(t, alias.Sequential{}.Generate(42, 0), g.Generate(42, 0)[1:], "ids must be obfuscated")
+
+	_, err = alias.NewHashids("", alias.Base62)
+	require.Error(t, err)
+
+	_, err = alias.NewHashids("secret", "a")
+	require.Error(t, err)
+}
+
+func TestHashidsDecodeInvalid(t *testing.T) {
+	g, err := alias.NewHashids("secret", alias.Base62)
+	require.NoError(t, err)
+
+	for _, a := range []string{"", "a", "a-b", "ZZZZZZZZZZZZZZZZZZZZZZZZZ"} {
+		_, ok := g.Decode(a)
+		require.False(t, ok, "alias %q must not be decodable", a)
+	}
+
+	a := g.Generate(42, 0)
+	lottery := a[0] + 1
+	if lottery > 'z' {
+		lottery = 'a'
+	}
+	_, ok := g.Decode(string(lottery) + a[1:])
+	require.False(t, ok, "alias with a wrong lottery character must not be decodable")
+}
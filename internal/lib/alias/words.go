@@ -0,0 +1,36 @@
+package alias
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"math/big"
+	"strings"
+)
+
+//go:embed words.txt
+var wordlist string
+
+var words = strings.Fields(wordlist)
+
+// Words mints human-readable aliases like "calm-lake-oak" from an embedded wordlist.
+// Every retry adds a word.
+type Words struct {
+	Count int
+}
+
+func (g Words) Generate(_ int64, attempt int) string {
+	parts := make([]string, g.Count+attempt)
+	for i := range parts {
+		parts[i] = words[randomIndex(len(words))]
+	}
+
+	return strings.Join(parts, "-")
+}
+
+func randomIndex(n int) int {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+	return int(i.Int64())
+}
@@ -0,0 +1,31 @@
+package alias_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/alias"
+)
+
+func TestWords(t *testing.T) {
+	g := alias.Words{Count: 4}
+
+	seen := make(map[string]struct{})
+	for range 1000 {
+		a := g.Generate(0, 0)
+		requireAlphabet(t, a, "abcdefghijklmnopqrstuvwxyz-")
+
+		parts := strings.Split(a, "-")
+		require.Len(t, parts, 4)
+		for _, part := range parts {
+			require.NotEmpty(t, part)
+		}
+
+		_, exists := seen[a]
+		require.False(t, exists, "duplicate alias %q", a)
+		seen[a] = struct{}{}
+	}
+
+	require.Len(t, strings.Split(g.Generate(0, 1), "-"), 5, "retries must add a word")
+}
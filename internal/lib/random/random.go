@@ -1,15 +1,25 @@
 package random
 
-import "math/rand"
+import "crypto/rand"
 
 const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
-// Alias generates a random string of given length
+// maxByte is the largest multiple of len(charset) that fits in a byte.
+// Bytes at or above it are skipped, so every character is equally likely.
+const maxByte = 256 - 256%len(charset)
+
+// Alias generates a cryptographically secure random string of given length
 // characters are a-z, A-Z, 0-9
 func Alias(length int) string {
-	s := make([]byte, length)
-	for i := range s {
-		s[i] = charset[rand.Intn(len(charset))]
+	s := make([]byte, 0, length)
+	buf := make([]byte, length)
+	for len(s) < length {
+		rand.Read(buf)
+		for _, b := range buf {
+			if int(b) < maxByte && len(s) < length {
+				s = append(s, charset[int(b)%len(charset)])
+			}
+		}
 	}
 	return string(s)
 }
@@ -0,0 +1,31 @@
+package random_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/random"
+)
+
+const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+func TestAlias(t *testing.T) {
+	for _, length := range []int{0, 1, 6, 64} {
+		alias := random.Alias(length)
+		require.Len(t, alias, length)
+		for _, c := range alias {
+			require.True(t, strings.ContainsRune(charset, c), "unexpected character %q", c)
+		}
+	}
+}
+
+func TestAliasUnique(t *testing.T) {
+	seen := make(map[string]struct{})
+	for range 10000 {
+		alias := random.Alias(10)
+		_, exists := seen[alias]
+		require.False(t, exists, "duplicate alias %q", alias)
+		seen[alias] = struct{}{}
+	}
+}
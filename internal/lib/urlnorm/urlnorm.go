@@ -0,0 +1,158 @@
+// Package urlnorm normalizes urls, so that equivalent urls compare equal.
+package urlnorm
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// defaultPorts maps schemes to the port they imply.
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// Normalizer normalizes urls. The zero value keeps every query parameter.
+type Normalizer struct {
+	// StripParams are query parameters removed from urls, like utm_source or fbclid.
+	// A trailing "*" matches every parameter with that prefix, e.g. "utm_*".
+	StripParams []string
+}
+
+// Normalize normalizes raw with the zero Normalizer.
+func Normalize(raw string) (string, error) {
+	return Normalizer{}.Normalize(raw)
+}
+
+// Normalize returns the normalized form of raw: the scheme and host are lower-cased,
+// the default port of the scheme is dropped, dot segments are resolved, an empty path
+// becomes "/" and query parameters are sorted by name, leaving out StripParams.
+// Escaping is kept as is. raw must have a scheme.
+func (n Normalizer) Normalize(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" {
+		return "", errors.New("url has no scheme")
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+
+	if host, port, err := net.SplitHostPort(u.Host); err == nil && port == defaultPorts[u.Scheme] {
+		u.Host = host
+		// SplitHostPort strips the brackets of IPv6 hosts
+		if strings.Contains(host, ":") {
+			u.Host = "[" + host + "]"
+		}
+	}
+
+	if u.Opaque == "" {
+		path := removeDotSegments(u.EscapedPath())
+		if path == "" && u.Host != "" {
+			path = "/"
+		}
+		if u.Path, err = url.PathUnescape(path); err != nil {
+			return "", err
+		}
+		u.RawPath = path
+	}
+
+	u.RawQuery = n.query(u.RawQuery)
+	u.ForceQuery = false
+
+	return u.String(), nil
+}
+
+// query sorts the parameters of rawQuery by name and drops StripParams.
+// Parameters are compared by their unescaped name but kept escaped as they were.
+func (n Normalizer) query(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	type param struct {
+		name string
+		raw  string
+	}
+
+	var params []param
+	for raw := range strings.SplitSeq(rawQuery, "&") {
+		if raw == "" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(raw, "=")
+		if unescaped, err := url.QueryUnescape(name); err == nil {
+			name = unescaped
+		}
+		if n.strip(name) {
+			continue
+		}
+
+		params = append(params, param{name: name, raw: raw})
+	}
+
+	// Repeated parameters keep their order, it may be meaningful
+	slices.SortStableFunc(params, func(a, b param) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	raws := make([]string, len(params))
+	for i, p := range params {
+		raws[i] = p.raw
+	}
+
+	return strings.Join(raws, "&")
+}
+
+func (n Normalizer) strip(name string) bool {
+	for _, pattern := range n.StripParams {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+			continue
+		}
+		if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// removeDotSegments resolves "." and ".." segments of an absolute path as described in RFC 3986, section 5.2.4.
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+
+		switch segment {
+		case ".":
+		case "..":
+			// The leading empty segment of an absolute path is never removed
+			if len(out) > 1 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, segment)
+			continue
+		}
+
+		// A trailing dot segment leaves the path ending with a slash
+		if last {
+			out = append(out, "")
+		}
+	}
+
+	return strings.Join(out, "/")
+}
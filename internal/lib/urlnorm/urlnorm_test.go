@@ -0,0 +1,77 @@
+package urlnorm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/urlnorm"
+)
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{name: "unchanged", raw: "https://example.com/a?b=c", want: "https://example.com/a?b=c"},
+		{name: "scheme and host case", raw: "HTTPS://Example.COM/Path", want: "https://example.com/Path"},
+		{name: "empty path", raw: "https://example.com", want: "https://example.com/"},
+		{name: "default http port", raw: "http://example.com:80/", want: "http://example.com/"},
+		{name: "default https port", raw: "https://example.com:443/", want: "https://example.com/"},
+		{name: "other port", raw: "https://example.com:80/", want: "https://example.com:80/"},
+		{name: "ipv6 default port", raw: "http://[::1]:80/", want: "http://[::1]/"},
+		{name: "dot segments", raw: "https://example.com/a/./b/../c", want: "https://example.com/a/c"},
+		{name: "trailing dot segment", raw: "https://example.com/a/b/..", want: "https://example.com/a/"},
+		{name: "dot segments above root", raw: "https://example.com/../../a", want: "https://example.com/a"},
+		{name: "dots in names", raw: "https://example.com/a.b/..c/", want: "https://example.com/a.b/..c/"},
+		{name: "escaping kept", raw: "https://example.com/a%2Fb/%7E?q=a%20b", want: "https://example.com/a%2Fb/%7E?q=a%20b"},
+		{name: "sorted query", raw: "https://example.com/?b=2&a=1&c", want: "https://example.com/?a=1&b=2&c"},
+		{name: "repeated params keep order", raw: "https://example.com/?b=2&a=3&b=1", want: "https://example.com/?a=3&b=2&b=1"},
+		{name: "empty query", raw: "https://example.com/?", want: "https://example.com/"},
+		{name: "fragment kept", raw: "https://example.com/#Top", want: "https://example.com/#Top"},
+		{name: "opaque", raw: "MAILTO:Someone@Example.com", want: "mailto:Someone@Example.com"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := urlnorm.Normalize(tc.raw)
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNormalizeInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, raw := range []string{"", "/path", "//example.com/", "http://%zz"} {
+		_, err := urlnorm.Normalize(raw)
+		require.Error(t, err, raw)
+	}
+}
+
+func TestNormalizerStripParams(t *testing.T) {
+	t.Parallel()
+
+	n := urlnorm.Normalizer{StripParams: []string{"utm_*", "fbclid"}}
+
+	cases := []struct {
+		raw  string
+		want string
+	}{
+		{raw: "https://Example.com:443/a/../b?utm_source=x&fbclid=y", want: "https://example.com/b"},
+		{raw: "https://example.com/?id=1&utm_medium=email&fbclid=y&gclid=z", want: "https://example.com/?gclid=z&id=1"},
+		{raw: "https://example.com/?fbclid_x=1&utm=2", want: "https://example.com/?fbclid_x=1&utm=2"},
+		{raw: "https://example.com/?utm%5Fsource=x", want: "https://example.com/"},
+	}
+
+	for _, tc := range cases {
+		got, err := n.Normalize(tc.raw)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, got, tc.raw)
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// AliasRules restrict the aliases chosen by clients.
+type AliasRules struct {
+	// Charset lists every character allowed in an alias.
+	Charset   string
+	MinLength int
+	MaxLength int
+	// CaseInsensitive folds aliases to lower case, so "Promo" and "promo" are the same link.
+	CaseInsensitive bool
+	// Reserved aliases cannot be chosen. The first segment of every route is reserved too.
+	Reserved []string
+}
+
+// DefaultAliasRules allow base62 characters, '-' and '_', case-sensitive.
+var DefaultAliasRules = AliasRules{
+	Charset:   "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_",
+	MinLength: 1,
+	MaxLength: 64,
+}
+
+// WithAliasRules sets the rules of client chosen aliases. By default DefaultAliasRules apply.
+func WithAliasRules(rules AliasRules) Option {
+	return func(h *Handler) {
+		h.aliasRules = rules
+	}
+}
+
+// registerAliasRules makes the "alias" validation tag check h.aliasRules.
+// Each rule fails with its own tag, so response.ValidationError can explain it.
+func (h *Handler) registerAliasRules() {
+	h.validator.RegisterValidation("alias_charset", func(fl validator.FieldLevel) bool {
+		return strings.Trim(fl.Field().String(), fl.Param()) == ""
+	})
+	h.validator.RegisterValidation("alias_reserved", func(fl validator.FieldLevel) bool {
+		return !h.isReserved(fl.Field().String())
+	})
+
+	charset := strings.NewReplacer(",", "0x2C", "|", "0x7C").Replace(h.aliasRules.Charset)
+	h.validator.RegisterAlias("alias", fmt.Sprintf(
+		"min=%d,max=%d,alias_charset=%s,alias_reserved",
+		h.aliasRules.MinLength, h.aliasRules.MaxLength, charset,
+	))
+}
+
+// reserve reserves the first segment of a route pattern like "GET /url/{alias}".
+func (h *Handler) reserve(pattern string) {
+	_, path, _ := strings.Cut(pattern, " ")
+	segment, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	if segment == "" || strings.HasPrefix(segment, "{") {
+		return
+	}
+	h.reserved[strings.ToLower(segment)] = struct{}{}
+}
+
+// isReserved reports whether alias is reserved. The check ignores case, because
+// aliases differing from a route only in case are confusing even when they work.
+func (h *Handler) isReserved(alias string) bool {
+	_, reserved := h.reserved[strings.ToLower(alias)]
+	return reserved
+}
+
+// normalizeAlias applies the case mode to an alias.
+func (h *Handler) normalizeAlias(alias string) string {
+	if h.aliasRules.CaseInsensitive {
+		return strings.ToLower(alias)
+	}
+	return alias
+}
+
+// pathAlias returns the normalized alias of routes like "/url/{alias}".
+func (h *Handler) pathAlias(r *http.Request) string {
+	return h.normalizeAlias(r.PathValue("alias"))
+}
+
+// generateAlias is the storage.AliasFunc of generated aliases. They follow the case mode,
+// reserved ones are returned empty so that the storage skips them.
+func (h *Handler) generateAlias(id int64, attempt int) string {
+	alias := h.normalizeAlias(h.aliases.Generate(id, attempt))
+	if h.isReserved(alias) {
+		return ""
+	}
+	return alias
+}
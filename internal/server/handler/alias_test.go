@@ -0,0 +1,218 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/alias"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+func TestAliasRules(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	rules := handler.AliasRules{
+		Charset:   "abcdefghijklmnopqrstuvwxyz0123456789-,",
+		MinLength: 3,
+		MaxLength: 10,
+		Reserved:  []string{"admin"},
+	}
+
+	cases := []struct {
+		name      string
+		alias     string
+		respError string
+	}{
+		{name: "Valid", alias: "promo-2025"},
+		{name: "Comma In Charset", alias: "a,b"},
+		{name: "Too Short", alias: "ab", respError: "'Alias' must be at least 3 characters long"},
+		{name: "Too Long", alias: "abcdefghijk", respError: "'Alias' must be at most 10 characters long"},
+		{name: "Slash", alias: "a/b", respError: `'Alias' may only contain the characters "abcdefghijklmnopqrstuvwxyz0123456789-,"`},
+		{name: "Unicode", alias: "прив", respError: `'Alias' may only contain the characters "abcdefghijklmnopqrstuvwxyz0123456789-,"`},
+		{name: "Upper Case", alias: "Promo", respError: `'Alias' may only contain the characters "abcdefghijklmnopqrstuvwxyz0123456789-,"`},
+		{name: "Route Prefix", alias: "url", respError: "'Alias' is reserved"},
+		{name: "Route Prefix Health", alias: "health", respError: "'Alias' is reserved"},
+		{name: "Configured Reserved Word", alias: "admin", respError: "'Alias' is reserved"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.respError == "" {
+				storageMock.EXPECT().
+					SaveURL(mock.Anything, storage.Link{Alias: tc.alias, URL: "https://google.com/"}).
+					Return(1, nil).
+					Once()
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "", handler.WithAliasRules(rules))
+
+			body, _ := json.Marshal(reqBody{URL: "https://google.com/", Alias: tc.alias})
+			req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+			req.SetBasicAuth("", "")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			var resp handler.CreateURLResponse
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			require.NoError(t, err)
+			require.Equal(t, tc.respError, resp.Error)
+
+			if tc.respError == "" {
+				require.Equal(t, http.StatusOK, w.Code)
+			} else {
+				require.Equal(t, http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestAliasRulesCaseInsensitive(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	rules := handler.DefaultAliasRules
+	rules.CaseInsensitive = true
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().
+		SaveURL(mock.Anything, storage.Link{Alias: "promo", URL: "https://google.com/"}).
+		Return(1, nil).
+		Once()
+	storageMock.EXPECT().
+		GetURL(mock.Anything, "promo").
+		Return(storage.Link{URL: "https://google.com/"}, nil).
+		Once()
+	storageMock.EXPECT().
+		DeleteURL(mock.Anything, "promo").
+		Return(nil).
+		Once()
+
+	h := handler.NewHandler(storageMock, 6, "", "", handler.WithAliasRules(rules))
+
+	body, _ := json.Marshal(reqBody{URL: "https://google.com/", Alias: "Promo"})
+	req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+	req.SetBasicAuth("", "")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp handler.CreateURLResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "promo", resp.Alias)
+
+	req = httptest.NewRequest(http.MethodGet, "/PROMO", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/url/PrOmO", nil)
+	req.SetBasicAuth("", "")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestGeneratedAliasRules(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	rules := handler.DefaultAliasRules
+	rules.CaseInsensitive = true
+
+	generatorMock := NewMockAliasGenerator(t)
+	generatorMock.EXPECT().Generate(int64(1), 0).Return("URL").Once()
+	generatorMock.EXPECT().Generate(int64(2), 1).Return("AbC").Once()
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().
+		SaveGeneratedURL(mock.Anything, storage.Link{URL: "https://google.com/"}, mock.Anything).
+		RunAndReturn(func(_ context.Context, link storage.Link, aliasOf storage.AliasFunc) (storage.Link, bool, error) {
+			require.Empty(t, aliasOf(1, 0), "reserved generated alias must be skipped")
+			link.ID = 2
+			link.Alias = aliasOf(2, 1)
+			return link, true, nil
+		}).
+		Once()
+
+	h := handler.NewHandler(storageMock, 6, "", "",
+		handler.WithAliasRules(rules),
+		handler.WithAliasGenerator(generatorMock),
+	)
+
+	body, _ := json.Marshal(reqBody{URL: "https://google.com/"})
+	req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+	req.SetBasicAuth("", "")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp handler.CreateURLResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, "abc", resp.Alias, "generated aliases must follow the case mode")
+}
+
+func TestGeneratedAliasesCaseInsensitive(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	rules := handler.DefaultAliasRules
+	rules.CaseInsensitive = true
+
+	hashids, err := alias.NewHashids("secret", alias.Base36)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name      string
+		generator handler.AliasGenerator
+	}{
+		{name: "Sequential", generator: alias.Sequential{Alphabet: alias.Base36}},
+		{name: "Hashids", generator: hashids},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			// Folding must not map different ids to the same alias, every collision costs an attempt
+			storageMock := NewMockStorage(t)
+			storageMock.EXPECT().
+				SaveGeneratedURL(mock.Anything, storage.Link{URL: "https://google.com/"}, mock.Anything).
+				RunAndReturn(func(_ context.Context, link storage.Link, aliasOf storage.AliasFunc) (storage.Link, bool, error) {
+					seen := make(map[string]int64)
+					for id := int64(1); id <= 1000; id++ {
+						a := aliasOf(id, 0)
+						require.Equal(t, tc.generator.Generate(id, 0), a, "aliases must not need folding")
+						other, exists := seen[a]
+						require.False(t, exists, "ids %d and %d fold to alias %q", other, id, a)
+						seen[a] = id
+					}
+					link.ID = 1
+					link.Alias = aliasOf(1, 0)
+					return link, true, nil
+				}).
+				Once()
+
+			h := handler.NewHandler(storageMock, 6, "", "",
+				handler.WithAliasRules(rules),
+				handler.WithAliasGenerator(tc.generator),
+			)
+
+			body, _ := json.Marshal(reqBody{URL: "https://google.com/"})
+			req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+			req.SetBasicAuth("", "")
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
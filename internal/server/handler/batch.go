@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/zulerne/url-shortener/internal/server/middleware"
+	"github.com/zulerne/url-shortener/internal/server/response"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+const maxBatchSize = 10000
+
+// maxBatchPasswords bounds the password protected items of a batch. Hashing a password
+// takes tens of milliseconds, more would not be done within the request timeout.
+const maxBatchPasswords = 20
+
+type BatchCreateURLResponse struct {
+	response.Response
+	// Results are in the order of the request items.
+	Results []CreateURLResponse `json:"results"`
+}
+
+// createURLs creates a link for every item of a JSON array of CreateURLRequest.
+// Items succeed or fail on their own, the outcome of each is reported in Results.
+func (h *Handler) createURLs(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.createURLs"
+	log := slog.With(
+		"op", op,
+		string(middleware.RequestIDKey), middleware.GetRequestID(r.Context()),
+	)
+
+	var reqs []CreateURLRequest
+	err := json.NewDecoder(r.Body).Decode(&reqs)
+	if err != nil {
+		log.Error("failed to decode request body", "error", err)
+		h.renderJSON(w, http.StatusBadRequest, response.Error(err.Error()))
+		return
+	}
+	log.Info("request received", "items", len(reqs))
+
+	if len(reqs) == 0 || len(reqs) > maxBatchSize {
+		err = fmt.Errorf("batch must contain between 1 and %d items", maxBatchSize)
+		log.Error("invalid batch size", "error", err)
+		h.renderJSON(w, http.StatusBadRequest, response.Error(err.Error()))
+		return
+	}
+
+	protected := 0
+	for _, req := range reqs {
+		if req.Password != "" {
+			protected++
+		}
+	}
+	if protected > maxBatchPasswords {
+		err = fmt.Errorf("batch must contain at most %d password protected items", maxBatchPasswords)
+		log.Error("too many password protected items", "error", err)
+		h.renderJSON(w, http.StatusBadRequest, response.Error(err.Error()))
+		return
+	}
+
+	results := make([]CreateURLResponse, len(reqs))
+	links := make([]storage.Link, 0, len(reqs))
+	// indexes maps links back to their position in the request
+	indexes := make([]int, 0, len(reqs))
+
+	now := h.now()
+	for i, req := range reqs {
+		link, err := h.newLink(r.Context(), req, now)
+		if errors.Is(err, errResolveURL) {
+			log.Error(errResolveURL.Error(), "error", err)
+			results[i].Response = response.Error(errResolveURL.Error())
+			continue
+		}
+		if err != nil {
+			results[i].Response = response.Error(err.Error())
+			continue
+		}
+
+		links = append(links, link)
+		indexes = append(indexes, i)
+	}
+
+	if len(links) > 0 {
+		saved, err := h.storage.SaveURLs(r.Context(), links, h.generateAlias)
+		if err != nil {
+			msg := "failed to save urls"
+			log.Error(msg, "error", err)
+			h.renderJSON(w, http.StatusInternalServerError, response.Error(msg))
+			return
+		}
+
+		for j, res := range saved {
+			i := indexes[j]
+			switch {
+			case res.Err == nil:
+				results[i] = CreateURLResponse{
+					Response: response.Ok(),
+					Alias:    res.Alias,
+					Reused:   res.Reused,
+				}
+				if !links[j].ExpiresAt.IsZero() {
+					results[i].ExpiresAt = &links[j].ExpiresAt
+				}
+			// Taken generated aliases are not the client's fault
+			case errors.Is(res.Err, storage.ErrAliasExists) && links[j].Alias != "":
+				results[i].Response = response.Error(storage.ErrAliasExists.Error())
+			default:
+				log.Error("failed to save url", "alias", links[j].Alias, "error", res.Err)
+				results[i].Response = response.Error("failed to save url")
+			}
+		}
+	}
+
+	log.Info("batch processed", "items", len(reqs), "valid", len(links))
+
+	h.renderJSON(w, http.StatusOK, BatchCreateURLResponse{
+		Response: response.Ok(),
+		Results:  results,
+	})
+}
@@ -0,0 +1,189 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/server/response"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+func TestCreateURLsHandler(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	protected := make([]string, 21)
+	for i := range protected {
+		protected[i] = `{"url": "https://google.com/", "password": "secret"}`
+	}
+
+	cases := []struct {
+		name      string
+		body      string
+		code      int
+		respError string
+		results   []handler.CreateURLResponse
+		mockSetup func(s *MockStorage)
+	}{
+		{
+			name: "Success",
+			body: `[{"url": "https://google.com/", "alias": "first"}, {"url": "https://example.com/", "alias": "second"}]`,
+			code: http.StatusOK,
+			results: []handler.CreateURLResponse{
+				{Response: response.Ok(), Alias: "first"},
+				{Response: response.Ok(), Alias: "second"},
+			},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveURLs(mock.Anything, []storage.Link{
+						{Alias: "first", URL: "https://google.com/"},
+						{Alias: "second", URL: "https://example.com/"},
+					}, mock.Anything).
+					Return([]storage.SaveResult{{ID: 1, Alias: "first"}, {ID: 2, Alias: "second"}}, nil).
+					Once()
+			},
+		},
+		{
+			name: "Per-item errors",
+			body: `[{"url": "https://google.com/", "alias": "exists"}, {"url": "invalid", "alias": "bad"}, {"url": "https://example.com/", "alias": "ok"}]`,
+			code: http.StatusOK,
+			results: []handler.CreateURLResponse{
+				{Response: response.Error(storage.ErrAliasExists.Error())},
+				{Response: response.Error("'URL' is not a valid url")},
+				{Response: response.Ok(), Alias: "ok"},
+			},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveURLs(mock.Anything, []storage.Link{
+						{Alias: "exists", URL: "https://google.com/"},
+						{Alias: "ok", URL: "https://example.com/"},
+					}, mock.Anything).
+					Return([]storage.SaveResult{{Err: storage.ErrAliasExists}, {ID: 2, Alias: "ok"}}, nil).
+					Once()
+			},
+		},
+		{
+			name: "All items invalid",
+			body: `[{"alias": "missing_url"}, {"url": "https://google.com/", "ttl": "-1h"}]`,
+			code: http.StatusOK,
+			results: []handler.CreateURLResponse{
+				{Response: response.Error("'URL' is required")},
+				{Response: response.Error("'TTL' must be a positive duration")},
+			},
+		},
+		{
+			name: "Generated alias",
+			body: `[{"url": "https://google.com/"}, {"url": "https://example.com/", "alias": "exists"}, {"url": "https://example.com/"}]`,
+			code: http.StatusOK,
+			results: []handler.CreateURLResponse{
+				{Response: response.Ok(), Alias: "abc123"},
+				{Response: response.Error(storage.ErrAliasExists.Error())},
+				// Taken generated aliases are not reported as taken
+				{Response: response.Error("failed to save url")},
+			},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveURLs(mock.Anything, []storage.Link{
+						{URL: "https://google.com/"},
+						{Alias: "exists", URL: "https://example.com/"},
+						{URL: "https://example.com/"},
+					}, mock.Anything).
+					Return([]storage.SaveResult{
+						{ID: 1, Alias: "abc123"},
+						{Err: storage.ErrAliasExists},
+						{Err: storage.ErrAliasExists},
+					}, nil).
+					Once()
+			},
+		},
+		{
+			name: "Reused",
+			body: `[{"url": "https://google.com/"}, {"url": "https://google.com/"}]`,
+			code: http.StatusOK,
+			results: []handler.CreateURLResponse{
+				{Response: response.Ok(), Alias: "abc123"},
+				{Response: response.Ok(), Alias: "abc123", Reused: true},
+			},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveURLs(mock.Anything, []storage.Link{
+						{URL: "https://google.com/"},
+						{URL: "https://google.com/"},
+					}, mock.Anything).
+					Return([]storage.SaveResult{{ID: 1, Alias: "abc123"}, {ID: 1, Alias: "abc123", Reused: true}}, nil).
+					Once()
+			},
+		},
+		{
+			name:      "Empty batch",
+			body:      `[]`,
+			code:      http.StatusBadRequest,
+			respError: "batch must contain between 1 and 10000 items",
+		},
+		{
+			name:      "Too many protected items",
+			body:      `[{"url": "https://example.com/"}, ` + strings.Join(protected, ", ") + `]`,
+			code:      http.StatusBadRequest,
+			respError: "batch must contain at most 20 password protected items",
+		},
+		{
+			name:      "Not an array",
+			body:      `{"url": "https://google.com/"}`,
+			code:      http.StatusBadRequest,
+			respError: "json: cannot unmarshal object into Go value of type []handler.CreateURLRequest",
+		},
+		{
+			name:      "SaveURLs Error",
+			body:      `[{"url": "https://google.com/", "alias": "fail"}]`,
+			code:      http.StatusInternalServerError,
+			respError: "failed to save urls",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveURLs(mock.Anything, []storage.Link{{Alias: "fail", URL: "https://google.com/"}}, mock.Anything).
+					Return(nil, errors.New("unexpected db error")).
+					Once()
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.mockSetup != nil {
+				tc.mockSetup(storageMock)
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "")
+
+			req := httptest.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader([]byte(tc.body)))
+			req.Header.Set("Content-Type", "application/json")
+			req.SetBasicAuth("", "")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+
+			var resp handler.BatchCreateURLResponse
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			require.NoError(t, err)
+			require.Equal(t, tc.respError, resp.Error)
+
+			if tc.results != nil {
+				require.Equal(t, tc.results, resp.Results)
+			}
+
+		})
+	}
+}
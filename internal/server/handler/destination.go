@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+// maxRedirectDepth bounds how many links of the shortener itself are followed
+// to find the final destination of a link.
+const maxRedirectDepth = 5
+
+// DomainMatcher reports whether a host is on a list of domains.
+// Match must be safe for concurrent use.
+type DomainMatcher interface {
+	Match(host string) bool
+}
+
+// DestinationRules restrict where links may point to.
+type DestinationRules struct {
+	// Schemes lists the allowed url schemes, lower-case.
+	Schemes []string
+	// Blocked domains cannot be linked to. Nil blocks nothing.
+	Blocked DomainMatcher
+	// Allowed domains are the only ones that can be linked to. Nil allows every domain.
+	Allowed DomainMatcher
+}
+
+// DefaultDestinationRules allow http and https links to any domain.
+var DefaultDestinationRules = DestinationRules{
+	Schemes: []string{"http", "https"},
+}
+
+// WithDestinationRules sets the rules of link destinations. By default DefaultDestinationRules apply.
+func WithDestinationRules(rules DestinationRules) Option {
+	return func(h *Handler) {
+		h.destinationRules = rules
+	}
+}
+
+// WithPublicHosts sets the hosts the shortener is reachable at, like "sho.rt" or "localhost:8080".
+// Links to short links on them are saved with the final destination instead.
+// By default no host is known.
+func WithPublicHosts(hosts []string) Option {
+	return func(h *Handler) {
+		for _, host := range hosts {
+			h.publicHosts[publicHost(host)] = struct{}{}
+		}
+	}
+}
+
+var (
+	errDomainNotAllowed = errors.New("'URL' domain is not allowed")
+	// errResolveURL is not the client's fault, unlike other errors of resolveDestination.
+	errResolveURL = errors.New("failed to resolve url")
+)
+
+// resolveDestination follows rawURL through short links of the shortener itself
+// and returns the url they finally point to, so that chains and loops of short links
+// are never saved. self is the alias of the link rawURL is saved to, if known.
+func (h *Handler) resolveDestination(ctx context.Context, rawURL string, self string) (string, error) {
+	visited := make(map[string]struct{})
+	if self != "" {
+		visited[self] = struct{}{}
+	}
+
+	for depth := 0; ; depth++ {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			// checkDestination rejects it
+			return rawURL, nil
+		}
+
+		alias, rest, own := h.ownAlias(u)
+		switch {
+		case !own:
+			return rawURL, nil
+		case alias == "":
+			return "", errors.New("'URL' points to the url shortener itself")
+		case depth == maxRedirectDepth:
+			return "", errors.New("'URL' redirects through too many short links")
+		}
+
+		if _, ok := visited[alias]; ok {
+			return "", errors.New("'URL' is a redirect loop")
+		}
+		visited[alias] = struct{}{}
+
+		link, err := h.storage.GetURL(ctx, alias)
+		// Saving the destination of restricted links would bypass their restrictions
+		switch {
+		case err == nil && link.PasswordHash != "":
+			return "", errors.New("'URL' points to a password protected short link")
+		case err == nil && link.MaxClicks > 0:
+			return "", errors.New("'URL' points to a short link with limited clicks")
+		case err == nil && link.Expired(h.now()):
+			err = storage.ErrExpired
+		case err == nil && link.Pending(h.now()):
+			return "", errors.New("'URL' points to a short link that is not available yet")
+		case err == nil:
+			rawURL, err = destination(link, rest, u.Query())
+		}
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrExpired) || errors.Is(err, storage.ErrExhausted) {
+			return "", errors.New("'URL' points to a missing or expired short link")
+		}
+		if err != nil {
+			return "", fmt.Errorf("%w: %w", errResolveURL, err)
+		}
+	}
+}
+
+// ownAlias reports whether u is on a public host of the shortener and returns the alias
+// it redirects through and the escaped path after it. The alias is empty if u is not a short link.
+func (h *Handler) ownAlias(u *url.URL) (alias string, rest string, own bool) {
+	_, own = h.publicHosts[publicHost(u.Host)]
+	if !own {
+		_, own = h.publicHosts[publicHost(u.Hostname())]
+	}
+	if !own {
+		return "", "", false
+	}
+
+	alias, rest, _ = strings.Cut(strings.TrimPrefix(u.EscapedPath(), "/"), "/")
+	alias, err := url.PathUnescape(alias)
+	if err != nil || alias == "" || h.isReserved(alias) {
+		return "", "", true
+	}
+
+	return h.normalizeAlias(alias), rest, true
+}
+
+// publicHost normalizes host for the lookup in publicHosts: lower case, without the
+// trailing dot of fully qualified names and without the ports 80 and 443, which urlnorm
+// drops from urls as well.
+func publicHost(host string) string {
+	host = strings.ToLower(host)
+
+	name, port, err := net.SplitHostPort(host)
+	if err != nil {
+		name, port = strings.Trim(host, "[]"), ""
+	}
+	name = strings.TrimSuffix(name, ".")
+
+	if port != "" && port != "80" && port != "443" {
+		return net.JoinHostPort(name, port)
+	}
+	if strings.Contains(name, ":") {
+		return "[" + name + "]"
+	}
+	return name
+}
+
+// checkDestination returns an error meant for the client if links to rawURL are not allowed.
+// Domain lists may change at runtime, so links are checked again on redirect.
+func (h *Handler) checkDestination(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return errInvalidURL
+	}
+
+	if !slices.Contains(h.destinationRules.Schemes, u.Scheme) {
+		return fmt.Errorf("'URL' scheme %q is not allowed", u.Scheme)
+	}
+
+	host := u.Hostname()
+	if h.destinationRules.Blocked != nil && h.destinationRules.Blocked.Match(host) {
+		return errDomainNotAllowed
+	}
+	if h.destinationRules.Allowed != nil && !h.destinationRules.Allowed.Match(host) {
+		return errDomainNotAllowed
+	}
+
+	return nil
+}
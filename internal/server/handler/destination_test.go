@@ -0,0 +1,334 @@
+package handler_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+// domains matches the hosts it contains.
+type domains []string
+
+func (d domains) Match(host string) bool {
+	return slices.Contains(d, host)
+}
+
+func TestDestinationRules(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	cases := []struct {
+		name      string
+		url       string
+		rules     handler.DestinationRules
+		respError string
+	}{
+		{name: "Default", url: "https://google.com/", rules: handler.DefaultDestinationRules},
+		{name: "Javascript", url: "javascript:alert(1)", rules: handler.DefaultDestinationRules, respError: `'URL' scheme "javascript" is not allowed`},
+		{name: "File", url: "file:///etc/passwd", rules: handler.DefaultDestinationRules, respError: `'URL' scheme "file" is not allowed`},
+		{name: "FTP", url: "ftp://example.com/file", rules: handler.DefaultDestinationRules, respError: `'URL' scheme "ftp" is not allowed`},
+		{name: "Upper Case Scheme", url: "JAVASCRIPT:alert(1)", rules: handler.DefaultDestinationRules, respError: `'URL' scheme "javascript" is not allowed`},
+		{name: "Allowed Scheme", url: "ftp://example.com/file", rules: handler.DestinationRules{Schemes: []string{"ftp"}}},
+		{
+			name:      "Blocked Domain",
+			url:       "https://evil.com:8443/login",
+			rules:     handler.DestinationRules{Schemes: []string{"https"}, Blocked: domains{"evil.com"}},
+			respError: "'URL' domain is not allowed",
+		},
+		{
+			name:      "Not Allowed Domain",
+			url:       "https://example.com/",
+			rules:     handler.DestinationRules{Schemes: []string{"https"}, Allowed: domains{"google.com"}},
+			respError: "'URL' domain is not allowed",
+		},
+		{
+			name:  "Allowed Domain",
+			url:   "https://google.com/",
+			rules: handler.DestinationRules{Schemes: []string{"https"}, Allowed: domains{"google.com"}},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.respError == "" {
+				storageMock.EXPECT().
+					SaveURL(mock.Anything, storage.Link{Alias: "dest", URL: tc.url}).
+					Return(1, nil).
+					Once()
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "", handler.WithDestinationRules(tc.rules))
+
+			body, _ := json.Marshal(reqBody{URL: tc.url, Alias: "dest"})
+			req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+			req.SetBasicAuth("", "")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			var resp handler.CreateURLResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+
+			if tc.respError == "" {
+				require.Equal(t, http.StatusOK, w.Code)
+			} else {
+				require.Equal(t, http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestDestinationRulesRedirect(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().
+		GetURL(mock.Anything, "blocked").
+		Return(storage.Link{URL: "https://evil.com/"}, nil).
+		Once()
+
+	// The domain was blocked after the link was created
+	blocked := NewMockDomainMatcher(t)
+	blocked.EXPECT().Match("evil.com").Return(true).Once()
+
+	recorderMock := NewMockClickRecorder(t)
+
+	h := handler.NewHandler(storageMock, 6, "", "",
+		handler.WithClickRecorder(recorderMock),
+		handler.WithDestinationRules(handler.DestinationRules{
+			Schemes: []string{"https"},
+			Blocked: blocked,
+		}),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/blocked", nil)
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusForbidden, w.Code)
+	require.Empty(t, w.Header().Get("Location"))
+}
+
+func TestPublicHosts(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	cases := []struct {
+		name      string
+		url       string
+		code      int
+		respError string
+		mockSetup func(s *MockStorage)
+	}{
+		{
+			name: "Chain Flattened",
+			url:  "https://SHO.RT/abc",
+			code: http.StatusOK,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{URL: "http://localhost:8080/def"}, nil).Once()
+				s.EXPECT().GetURL(mock.Anything, "def").Return(storage.Link{URL: "https://google.com/"}, nil).Once()
+				s.EXPECT().
+					SaveURL(mock.Anything, storage.Link{Alias: "new", URL: "https://google.com/"}).
+					Return(1, nil).
+					Once()
+			},
+		},
+		{
+			name: "Other Port",
+			url:  "http://localhost:9090/abc",
+			code: http.StatusOK,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveURL(mock.Anything, storage.Link{Alias: "new", URL: "http://localhost:9090/abc"}).
+					Return(1, nil).
+					Once()
+			},
+		},
+		{
+			name:      "Self Loop",
+			url:       "https://sho.rt/new",
+			code:      http.StatusBadRequest,
+			respError: "'URL' is a redirect loop",
+		},
+		{
+			name:      "Self Loop Fully Qualified",
+			url:       "http://sho.rt./new",
+			code:      http.StatusBadRequest,
+			respError: "'URL' is a redirect loop",
+		},
+		{
+			name:      "Self Loop Default Port",
+			url:       "https://sho.rt:443/new",
+			code:      http.StatusBadRequest,
+			respError: "'URL' is a redirect loop",
+		},
+		{
+			name:      "Self Loop Configured Default Port",
+			url:       "https://go.sho.rt/new",
+			code:      http.StatusBadRequest,
+			respError: "'URL' is a redirect loop",
+		},
+		{
+			name:      "Loop",
+			url:       "https://sho.rt/abc",
+			code:      http.StatusBadRequest,
+			respError: "'URL' is a redirect loop",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{URL: "https://sho.rt/def"}, nil).Once()
+				s.EXPECT().GetURL(mock.Anything, "def").Return(storage.Link{URL: "https://sho.rt/abc"}, nil).Once()
+			},
+		},
+		{
+			name:      "Too Deep",
+			url:       "https://sho.rt/a",
+			code:      http.StatusBadRequest,
+			respError: "'URL' redirects through too many short links",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					GetURL(mock.Anything, mock.Anything).
+					RunAndReturn(func(_ context.Context, alias string) (storage.Link, error) {
+						return storage.Link{URL: "https://sho.rt/" + alias + "a"}, nil
+					}).
+					Times(5)
+			},
+		},
+		{
+			name:      "Missing Link",
+			url:       "https://sho.rt/abc",
+			code:      http.StatusBadRequest,
+			respError: "'URL' points to a missing or expired short link",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{}, storage.ErrExpired).Once()
+			},
+		},
+		{
+			name: "Prefix Link Flattened",
+			url:  "https://sho.rt/abc/guide/intro?page=2",
+			code: http.StatusOK,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{URL: "https://example.com/docs", Prefix: true}, nil).Once()
+				s.EXPECT().
+					SaveURL(mock.Anything, storage.Link{Alias: "new", URL: "https://example.com/docs/guide/intro"}).
+					Return(1, nil).
+					Once()
+			},
+		},
+		{
+			name:      "Path On Plain Link",
+			url:       "https://sho.rt/abc/guide",
+			code:      http.StatusBadRequest,
+			respError: "'URL' points to a missing or expired short link",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{URL: "https://example.com/docs"}, nil).Once()
+			},
+		},
+		{
+			name:      "Protected Link",
+			url:       "https://sho.rt/abc",
+			code:      http.StatusBadRequest,
+			respError: "'URL' points to a password protected short link",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{URL: "https://example.com/", PasswordHash: "hash"}, nil).Once()
+			},
+		},
+		{
+			name:      "Limited Link",
+			url:       "https://sho.rt/abc",
+			code:      http.StatusBadRequest,
+			respError: "'URL' points to a short link with limited clicks",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{URL: "https://example.com/", MaxClicks: 1}, nil).Once()
+			},
+		},
+		{
+			name:      "Scheduled Link",
+			url:       "https://sho.rt/abc",
+			code:      http.StatusBadRequest,
+			respError: "'URL' points to a short link that is not available yet",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{URL: "https://example.com/", ValidFrom: time.Now().Add(time.Hour)}, nil).Once()
+			},
+		},
+		{
+			name:      "Not A Link",
+			url:       "https://sho.rt/url/abc/info",
+			code:      http.StatusBadRequest,
+			respError: "'URL' points to the url shortener itself",
+		},
+		{
+			name:      "Root",
+			url:       "https://sho.rt",
+			code:      http.StatusBadRequest,
+			respError: "'URL' points to the url shortener itself",
+		},
+		{
+			name:      "Storage Error",
+			url:       "https://sho.rt/abc",
+			code:      http.StatusInternalServerError,
+			respError: "failed to resolve url",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{}, errors.New("unexpected db error")).Once()
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.mockSetup != nil {
+				tc.mockSetup(storageMock)
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "", handler.WithPublicHosts([]string{"sho.rt", "localhost:8080", "go.sho.rt:443"}))
+
+			body, _ := json.Marshal(reqBody{URL: tc.url, Alias: "new"})
+			req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+			req.SetBasicAuth("", "")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+
+			var resp handler.CreateURLResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+		})
+	}
+}
+
+func TestPublicHostsUpdateLoop(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{URL: "https://sho.rt/self"}, nil).Once()
+
+	h := handler.NewHandler(storageMock, 6, "", "", handler.WithPublicHosts([]string{"sho.rt"}))
+
+	req := httptest.NewRequest(http.MethodPatch, "/url/self", bytes.NewReader([]byte(`{"url": "https://sho.rt/abc"}`)))
+	req.SetBasicAuth("", "")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.Contains(t, w.Body.String(), "'URL' is a redirect loop")
+}
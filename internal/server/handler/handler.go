@@ -1,35 +1,125 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/zulerne/url-shortener/internal/lib/alias"
+	"github.com/zulerne/url-shortener/internal/lib/urlnorm"
 	"github.com/zulerne/url-shortener/internal/server/middleware"
+	"github.com/zulerne/url-shortener/internal/storage"
 )
 
 // Storage defines the interface for URL storage operations.
 // This allows swapping implementations (sqlite, postgres, redis, etc.)
+// Implementations must stop work and return ctx.Err() once ctx is done.
 type Storage interface {
-	SaveURL(url string, alias string) (int64, error)
-	GetURL(alias string) (string, error)
-	DeleteURL(alias string) error
+	SaveURL(ctx context.Context, link storage.Link) (int64, error)
+	SaveGeneratedURL(ctx context.Context, link storage.Link, alias storage.AliasFunc) (storage.Link, bool, error)
+	SaveURLs(ctx context.Context, links []storage.Link, alias storage.AliasFunc) ([]storage.SaveResult, error)
+	GetURL(ctx context.Context, alias string) (storage.Link, error)
+	GetLink(ctx context.Context, alias string) (storage.Link, error)
+	ConsumeClick(ctx context.Context, alias string) error
+	UpdateURL(ctx context.Context, alias string, url string) error
+	UpdateUTM(ctx context.Context, alias string, utm storage.UTM) error
+	ListURLs(ctx context.Context, params storage.ListParams) ([]storage.Link, error)
+	DeleteURL(ctx context.Context, alias string) error
+	GetStats(ctx context.Context, alias string) (storage.Stats, error)
+}
+
+// ClickRecorder records redirects for analytics. Record must not block.
+type ClickRecorder interface {
+	Record(click storage.Click)
+}
+
+// AliasGenerator mints aliases for links created without one. Generate has
+// the signature of storage.AliasFunc and must be safe for concurrent use.
+type AliasGenerator interface {
+	Generate(id int64, attempt int) string
 }
 
 // Handler holds all dependencies for HTTP handlers
 type Handler struct {
-	storage     Storage
-	validator   *validator.Validate
-	aliasLength int
+	storage          Storage
+	clicks           ClickRecorder
+	aliases          AliasGenerator
+	aliasRules       AliasRules
+	reserved         map[string]struct{}
+	dedup            bool
+	urls             urlnorm.Normalizer
+	destinationRules DestinationRules
+	publicHosts      map[string]struct{}
+	redirectType     int
+	passwords        *throttle
+	notYetAvailable  []byte
+	now              func() time.Time
+	validator        *validator.Validate
+}
+
+// Option configures a Handler.
+type Option func(*Handler)
+
+// WithClickRecorder sets the recorder of redirects. By default clicks are not recorded.
+func WithClickRecorder(recorder ClickRecorder) Option {
+	return func(h *Handler) {
+		h.clicks = recorder
+	}
+}
+
+// WithAliasGenerator sets the generator of aliases.
+// By default aliases are random base62 strings of aliasLength characters.
+func WithAliasGenerator(generator AliasGenerator) Option {
+	return func(h *Handler) {
+		h.aliases = generator
+	}
+}
+
+// WithDedup makes links created without alias and expiry reuse an existing
+// link with the same normalized destination. It is off by default.
+func WithDedup(enabled bool) Option {
+	return func(h *Handler) {
+		h.dedup = enabled
+	}
+}
+
+// WithStripParams sets query parameters removed from destinations, like utm_source.
+// A trailing "*" matches every parameter with that prefix. By default none are removed.
+func WithStripParams(params []string) Option {
+	return func(h *Handler) {
+		h.urls.StripParams = params
+	}
+}
+
+// WithClock sets the source of the current time, used for expiry, activation and clicks.
+// By default it is time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(h *Handler) {
+		h.now = now
+	}
 }
 
 // NewHandler creates a new Handler with the given dependencies
-func NewHandler(storage Storage, aliasLength int, user, password string) http.Handler {
+func NewHandler(storage Storage, aliasLength int, user, password string, opts ...Option) http.Handler {
 	h := &Handler{
-		storage:     storage,
-		validator:   validator.New(),
-		aliasLength: aliasLength,
+		storage:          storage,
+		clicks:           noopRecorder{},
+		aliases:          alias.Random{Length: aliasLength},
+		aliasRules:       DefaultAliasRules,
+		destinationRules: DefaultDestinationRules,
+		publicHosts:      make(map[string]struct{}),
+		redirectType:     http.StatusTemporaryRedirect,
+		passwords:        newThrottle(defaultPasswordMaxFailures, defaultPasswordWindow),
+		now:              time.Now,
+		reserved:         make(map[string]struct{}),
+		validator:        validator.New(),
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
 
 	mux := http.NewServeMux()
@@ -38,10 +128,35 @@ func NewHandler(storage Storage, aliasLength int, user, password string) http.Ha
 		user: password,
 	})
 
-	// Register routes
-	mux.HandleFunc("GET /health", h.healthCheck)
-	mux.Handle("POST /url", authMiddleware(http.HandlerFunc(h.createURL)))
-	mux.HandleFunc("GET /{alias}", h.redirect)
+	routes := []struct {
+		pattern string
+		handler http.Handler
+	}{
+		{"GET /health", http.HandlerFunc(h.healthCheck)},
+		{"GET /url", authMiddleware(http.HandlerFunc(h.listURLs))},
+		{"POST /url", authMiddleware(http.HandlerFunc(h.createURL))},
+		{"POST /url/batch", authMiddleware(http.HandlerFunc(h.createURLs))},
+		{"PATCH /url/{alias}", authMiddleware(http.HandlerFunc(h.updateURL))},
+		{"DELETE /url/{alias}", authMiddleware(http.HandlerFunc(h.deleteURL))},
+		{"GET /url/{alias}/info", authMiddleware(http.HandlerFunc(h.urlInfo))},
+		{"GET /url/{alias}/stats", authMiddleware(http.HandlerFunc(h.urlStats))},
+		{"PUT /url/{alias}/utm", authMiddleware(http.HandlerFunc(h.updateUTM))},
+		{"GET /{alias}", http.HandlerFunc(h.redirect)},
+		{"GET /{alias}/{rest...}", http.HandlerFunc(h.redirect)},
+		{"POST /{alias}", http.HandlerFunc(h.unlock)},
+		{"POST /{alias}/{rest...}", http.HandlerFunc(h.unlock)},
+	}
+
+	// Register routes, aliases must not shadow them
+	for _, route := range routes {
+		mux.Handle(route.pattern, route.handler)
+		h.reserve(route.pattern)
+	}
+	for _, word := range h.aliasRules.Reserved {
+		h.reserved[strings.ToLower(word)] = struct{}{}
+	}
+	h.registerAliasRules()
+
 	// Apply middleware chain (order: first listed = first executed)
 	// Recoverer -> RequestID -> Logger -> handler
 	return middleware.Chain(mux,
@@ -63,3 +178,7 @@ func (h *Handler) renderJSON(w http.ResponseWriter, status int, v any) {
 		slog.Error("failed to encode response", "error", err)
 	}
 }
+
+type noopRecorder struct{}
+
+func (noopRecorder) Record(storage.Click) {}
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/zulerne/url-shortener/internal/server/middleware"
+	"github.com/zulerne/url-shortener/internal/server/response"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+type URLInfoResponse struct {
+	response.Response
+	URLItem
+}
+
+// urlInfo describes a link without redirecting. Expired links are reported too.
+func (h *Handler) urlInfo(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.urlInfo"
+
+	log := slog.With(
+		"op", op,
+		string(middleware.RequestIDKey), middleware.GetRequestID(r.Context()),
+	)
+
+	alias := h.pathAlias(r)
+
+	link, err := h.storage.GetLink(r.Context(), alias)
+	if err != nil {
+		msg := "failed to get url info"
+		log.Error(msg, "error", err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.renderJSON(w, http.StatusNotFound, response.Error(storage.ErrNotFound.Error()))
+			return
+		}
+
+		h.renderJSON(w, http.StatusInternalServerError, response.Error(msg))
+		return
+	}
+
+	h.renderJSON(w, http.StatusOK, URLInfoResponse{
+		Response: response.Ok(),
+		URLItem:  newURLItem(link),
+	})
+}
@@ -0,0 +1,132 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+func TestURLInfoHandler(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	user := "user"
+	pass := "pass"
+
+	createdAt := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	expiresAt := time.Date(2025, 4, 1, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		code      int
+		alias     string
+		pass      string
+		respError string
+		resp      handler.URLItem
+		mockSetup func(s *MockStorage)
+	}{
+		{
+			name:  "Success",
+			code:  http.StatusOK,
+			alias: "test_alias",
+			pass:  pass,
+			resp: handler.URLItem{
+				Alias:     "test_alias",
+				URL:       "https://google.com",
+				CreatedAt: createdAt,
+				ExpiresAt: expiresAt,
+				CreatedBy: "admin",
+				Clicks:    42,
+			},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					GetLink(mock.Anything, "test_alias").
+					Return(storage.Link{
+						ID:        1,
+						Alias:     "test_alias",
+						URL:       "https://google.com",
+						CreatedAt: createdAt,
+						ExpiresAt: expiresAt,
+						CreatedBy: "admin",
+						Clicks:    42,
+					}, nil).
+					Once()
+			},
+		},
+		{
+			name:      "NotFound",
+			code:      http.StatusNotFound,
+			alias:     "not_found",
+			pass:      pass,
+			respError: storage.ErrNotFound.Error(),
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					GetLink(mock.Anything, "not_found").
+					Return(storage.Link{}, storage.ErrNotFound).
+					Once()
+			},
+		},
+		{
+			name:      "GetLink Internal Error",
+			code:      http.StatusInternalServerError,
+			alias:     "fail",
+			pass:      pass,
+			respError: "failed to get url info",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					GetLink(mock.Anything, "fail").
+					Return(storage.Link{}, errors.New("unexpected db error")).
+					Once()
+			},
+		},
+		{
+			name:  "Unauthorized",
+			code:  http.StatusUnauthorized,
+			alias: "test_alias",
+			pass:  "wrong_pass",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.mockSetup != nil {
+				tc.mockSetup(storageMock)
+			}
+
+			h := handler.NewHandler(storageMock, 6, user, pass)
+
+			req := httptest.NewRequest(http.MethodGet, "/url/"+tc.alias+"/info", nil)
+			req.SetBasicAuth(user, tc.pass)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+
+			if tc.code == http.StatusUnauthorized {
+				return
+			}
+
+			var resp handler.URLInfoResponse
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			require.NoError(t, err)
+			require.Equal(t, tc.respError, resp.Error)
+
+			if tc.respError == "" {
+				require.Equal(t, tc.resp, resp.URLItem)
+			}
+		})
+	}
+}
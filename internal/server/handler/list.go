@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/zulerne/url-shortener/internal/server/middleware"
+	"github.com/zulerne/url-shortener/internal/server/response"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+const (
+	orderAsc  = "asc"
+	orderDesc = "desc"
+)
+
+type ListURLsResponse struct {
+	response.Response
+	URLs []URLItem `json:"urls"`
+	// NextCursor is passed as the cursor query parameter to get the next page.
+	// It is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type URLItem struct {
+	Alias     string    `json:"alias"`
+	URL       string    `json:"url"`
+	CreatedAt time.Time `json:"created_at,omitzero"`
+	UpdatedAt time.Time `json:"updated_at,omitzero"`
+	ValidFrom time.Time `json:"valid_from,omitzero"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	Clicks    int64     `json:"clicks"`
+	// RedirectType is omitted for links redirecting with the service default.
+	RedirectType int  `json:"redirect_type,omitempty"`
+	ForwardQuery bool `json:"forward_query,omitempty"`
+	Prefix       bool `json:"prefix,omitempty"`
+	UTM          UTM  `json:"utm,omitzero"`
+	// Protected is set for links behind a password.
+	Protected bool `json:"protected,omitempty"`
+	// MaxClicks and ClicksLeft are omitted for links with unlimited clicks.
+	MaxClicks  int64  `json:"max_clicks,omitempty"`
+	ClicksLeft *int64 `json:"clicks_left,omitempty"`
+}
+
+func newURLItem(link storage.Link) URLItem {
+	item := URLItem{
+		Alias:        link.Alias,
+		URL:          link.URL,
+		CreatedAt:    link.CreatedAt,
+		UpdatedAt:    link.UpdatedAt,
+		ValidFrom:    link.ValidFrom,
+		ExpiresAt:    link.ExpiresAt,
+		CreatedBy:    link.CreatedBy,
+		Clicks:       link.Clicks,
+		RedirectType: link.RedirectType,
+		ForwardQuery: link.ForwardQuery,
+		Prefix:       link.Prefix,
+		UTM:          newUTM(link.UTM),
+		Protected:    link.PasswordHash != "",
+		MaxClicks:    link.MaxClicks,
+	}
+	if link.MaxClicks > 0 {
+		left := max(link.MaxClicks-link.ClicksUsed, 0)
+		item.ClicksLeft = &left
+	}
+
+	return item
+}
+
+// listURLs returns links page by page, ordered by creation time.
+// Query parameters: limit, cursor, order (asc|desc), alias_prefix, url_contains.
+func (h *Handler) listURLs(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.listURLs"
+
+	log := slog.With(
+		"op", op,
+		string(middleware.RequestIDKey), middleware.GetRequestID(r.Context()),
+	)
+
+	params, err := parseListParams(r)
+	if err != nil {
+		log.Info("invalid query", "error", err)
+		h.renderJSON(w, http.StatusBadRequest, response.Error(err.Error()))
+		return
+	}
+
+	// One extra link tells whether there is a next page
+	limit := params.Limit
+	params.Limit++
+
+	links, err := h.storage.ListURLs(r.Context(), params)
+	if err != nil {
+		msg := "failed to list urls"
+		log.Error(msg, "error", err)
+		h.renderJSON(w, http.StatusInternalServerError, response.Error(msg))
+		return
+	}
+
+	resp := ListURLsResponse{
+		Response: response.Ok(),
+		URLs:     make([]URLItem, 0, min(len(links), limit)),
+	}
+
+	if len(links) > limit {
+		links = links[:limit]
+		resp.NextCursor = strconv.FormatInt(links[len(links)-1].ID, 10)
+	}
+
+	for _, link := range links {
+		resp.URLs = append(resp.URLs, newURLItem(link))
+	}
+
+	h.renderJSON(w, http.StatusOK, resp)
+}
+
+func parseListParams(r *http.Request) (storage.ListParams, error) {
+	query := r.URL.Query()
+
+	params := storage.ListParams{
+		Limit:       defaultPageSize,
+		Desc:        true,
+		AliasPrefix: query.Get("alias_prefix"),
+		URLContains: query.Get("url_contains"),
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return storage.ListParams{}, fmt.Errorf("'limit' must be between 1 and %d", maxPageSize)
+		}
+		params.Limit = limit
+	}
+
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || cursor < 1 {
+			return storage.ListParams{}, errors.New("'cursor' is invalid")
+		}
+		params.Cursor = cursor
+	}
+
+	switch query.Get("order") {
+	case "", orderDesc:
+	case orderAsc:
+		params.Desc = false
+	default:
+		return storage.ListParams{}, fmt.Errorf("'order' must be %q or %q", orderAsc, orderDesc)
+	}
+
+	return params, nil
+}
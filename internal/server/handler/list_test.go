@@ -0,0 +1,162 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+func TestListURLsHandler(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	user := "user"
+	pass := "pass"
+
+	links := []storage.Link{
+		{ID: 3, Alias: "c", URL: "https://c.com"},
+		{ID: 2, Alias: "b", URL: "https://b.com"},
+		{ID: 1, Alias: "a", URL: "https://a.com"},
+	}
+
+	cases := []struct {
+		name       string
+		query      string
+		code       int
+		pass       string
+		respError  string
+		aliases    []string
+		nextCursor string
+		mockSetup  func(s *MockStorage)
+	}{
+		{
+			name:    "Defaults",
+			code:    http.StatusOK,
+			pass:    pass,
+			aliases: []string{"c", "b", "a"},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					ListURLs(mock.Anything, storage.ListParams{Limit: 21, Desc: true}).
+					Return(links, nil).
+					Once()
+			},
+		},
+		{
+			name:       "Has Next Page",
+			query:      "?limit=2",
+			code:       http.StatusOK,
+			pass:       pass,
+			aliases:    []string{"c", "b"},
+			nextCursor: "2",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					ListURLs(mock.Anything, storage.ListParams{Limit: 3, Desc: true}).
+					Return(links, nil).
+					Once()
+			},
+		},
+		{
+			name:    "All Params",
+			query:   "?limit=5&cursor=10&order=asc&alias_prefix=ab&url_contains=google",
+			code:    http.StatusOK,
+			pass:    pass,
+			aliases: []string{},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					ListURLs(mock.Anything, storage.ListParams{
+						Cursor:      10,
+						Limit:       6,
+						AliasPrefix: "ab",
+						URLContains: "google",
+					}).
+					Return(nil, nil).
+					Once()
+			},
+		},
+		{
+			name:      "Limit Too Large",
+			query:     "?limit=101",
+			code:      http.StatusBadRequest,
+			pass:      pass,
+			respError: "'limit' must be between 1 and 100",
+		},
+		{
+			name:      "Invalid Cursor",
+			query:     "?cursor=abc",
+			code:      http.StatusBadRequest,
+			pass:      pass,
+			respError: "'cursor' is invalid",
+		},
+		{
+			name:      "Invalid Order",
+			query:     "?order=random",
+			code:      http.StatusBadRequest,
+			pass:      pass,
+			respError: `'order' must be "asc" or "desc"`,
+		},
+		{
+			name:      "ListURLs Internal Error",
+			code:      http.StatusInternalServerError,
+			pass:      pass,
+			respError: "failed to list urls",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					ListURLs(mock.Anything, mock.Anything).
+					Return(nil, errors.New("unexpected db error")).
+					Once()
+			},
+		},
+		{
+			name: "Unauthorized",
+			code: http.StatusUnauthorized,
+			pass: "wrong_pass",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.mockSetup != nil {
+				tc.mockSetup(storageMock)
+			}
+
+			h := handler.NewHandler(storageMock, 6, user, pass)
+
+			req := httptest.NewRequest(http.MethodGet, "/url"+tc.query, nil)
+			req.SetBasicAuth(user, tc.pass)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+
+			if tc.code == http.StatusUnauthorized {
+				return
+			}
+
+			var resp handler.ListURLsResponse
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			require.NoError(t, err)
+			require.Equal(t, tc.respError, resp.Error)
+
+			if tc.respError == "" {
+				aliases := make([]string, 0, len(resp.URLs))
+				for _, item := range resp.URLs {
+					aliases = append(aliases, item.Alias)
+				}
+				require.Equal(t, tc.aliases, aliases)
+				require.Equal(t, tc.nextCursor, resp.NextCursor)
+			}
+		})
+	}
+}
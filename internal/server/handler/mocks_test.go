@@ -5,9 +5,241 @@
 package handler_test
 
 import (
+	"context"
+
 	mock "github.com/stretchr/testify/mock"
+	"github.com/zulerne/url-shortener/internal/storage"
 )
 
+// NewMockAliasGenerator creates a new instance of MockAliasGenerator. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockAliasGenerator(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockAliasGenerator {
+	mock := &MockAliasGenerator{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockAliasGenerator is an autogenerated mock type for the AliasGenerator type
+type MockAliasGenerator struct {
+	mock.Mock
+}
+
+type MockAliasGenerator_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockAliasGenerator) EXPECT() *MockAliasGenerator_Expecter {
+	return &MockAliasGenerator_Expecter{mock: &_m.Mock}
+}
+
+// Generate provides a mock function for the type MockAliasGenerator
+func (_mock *MockAliasGenerator) Generate(id int64, attempt int) string {
+	ret := _mock.Called(id, attempt)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Generate")
+	}
+
+	var r0 string
+	if returnFunc, ok := ret.Get(0).(func(int64, int) string); ok {
+		r0 = returnFunc(id, attempt)
+	} else {
+		r0 = ret.Get(0).(string)
+	}
+	return r0
+}
+
+// MockAliasGenerator_Generate_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Generate'
+type MockAliasGenerator_Generate_Call struct {
+	*mock.Call
+}
+
+// Generate is a helper method to define mock.On call
+//   - id int64
+//   - attempt int
+func (_e *MockAliasGenerator_Expecter) Generate(id interface{}, attempt interface{}) *MockAliasGenerator_Generate_Call {
+	return &MockAliasGenerator_Generate_Call{Call: _e.mock.On("Generate", id, attempt)}
+}
+
+func (_c *MockAliasGenerator_Generate_Call) Run(run func(id int64, attempt int)) *MockAliasGenerator_Generate_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 int64
+		if args[0] != nil {
+			arg0 = args[0].(int64)
+		}
+		var arg1 int
+		if args[1] != nil {
+			arg1 = args[1].(int)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockAliasGenerator_Generate_Call) Return(s string) *MockAliasGenerator_Generate_Call {
+	_c.Call.Return(s)
+	return _c
+}
+
+func (_c *MockAliasGenerator_Generate_Call) RunAndReturn(run func(id int64, attempt int) string) *MockAliasGenerator_Generate_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// NewMockClickRecorder creates a new instance of MockClickRecorder. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockClickRecorder(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockClickRecorder {
+	mock := &MockClickRecorder{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockClickRecorder is an autogenerated mock type for the ClickRecorder type
+type MockClickRecorder struct {
+	mock.Mock
+}
+
+type MockClickRecorder_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockClickRecorder) EXPECT() *MockClickRecorder_Expecter {
+	return &MockClickRecorder_Expecter{mock: &_m.Mock}
+}
+
+// Record provides a mock function for the type MockClickRecorder
+func (_mock *MockClickRecorder) Record(click storage.Click) {
+	_mock.Called(click)
+	return
+}
+
+// MockClickRecorder_Record_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Record'
+type MockClickRecorder_Record_Call struct {
+	*mock.Call
+}
+
+// Record is a helper method to define mock.On call
+//   - click storage.Click
+func (_e *MockClickRecorder_Expecter) Record(click interface{}) *MockClickRecorder_Record_Call {
+	return &MockClickRecorder_Record_Call{Call: _e.mock.On("Record", click)}
+}
+
+func (_c *MockClickRecorder_Record_Call) Run(run func(click storage.Click)) *MockClickRecorder_Record_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 storage.Click
+		if args[0] != nil {
+			arg0 = args[0].(storage.Click)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockClickRecorder_Record_Call) Return() *MockClickRecorder_Record_Call {
+	_c.Call.Return()
+	return _c
+}
+
+func (_c *MockClickRecorder_Record_Call) RunAndReturn(run func(click storage.Click)) *MockClickRecorder_Record_Call {
+	_c.Run(run)
+	return _c
+}
+
+// NewMockDomainMatcher creates a new instance of MockDomainMatcher. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
+// The first argument is typically a *testing.T value.
+func NewMockDomainMatcher(t interface {
+	mock.TestingT
+	Cleanup(func())
+}) *MockDomainMatcher {
+	mock := &MockDomainMatcher{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
+
+// MockDomainMatcher is an autogenerated mock type for the DomainMatcher type
+type MockDomainMatcher struct {
+	mock.Mock
+}
+
+type MockDomainMatcher_Expecter struct {
+	mock *mock.Mock
+}
+
+func (_m *MockDomainMatcher) EXPECT() *MockDomainMatcher_Expecter {
+	return &MockDomainMatcher_Expecter{mock: &_m.Mock}
+}
+
+// Match provides a mock function for the type MockDomainMatcher
+func (_mock *MockDomainMatcher) Match(host string) bool {
+	ret := _mock.Called(host)
+
+	if len(ret) == 0 {
+		panic("no return value specified for Match")
+	}
+
+	var r0 bool
+	if returnFunc, ok := ret.Get(0).(func(string) bool); ok {
+		r0 = returnFunc(host)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// MockDomainMatcher_Match_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Match'
+type MockDomainMatcher_Match_Call struct {
+	*mock.Call
+}
+
+// Match is a helper method to define mock.On call
+//   - host string
+func (_e *MockDomainMatcher_Expecter) Match(host interface{}) *MockDomainMatcher_Match_Call {
+	return &MockDomainMatcher_Match_Call{Call: _e.mock.On("Match", host)}
+}
+
+func (_c *MockDomainMatcher_Match_Call) Run(run func(host string)) *MockDomainMatcher_Match_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 string
+		if args[0] != nil {
+			arg0 = args[0].(string)
+		}
+		run(
+			arg0,
+		)
+	})
+	return _c
+}
+
+func (_c *MockDomainMatcher_Match_Call) Return(b bool) *MockDomainMatcher_Match_Call {
+	_c.Call.Return(b)
+	return _c
+}
+
+func (_c *MockDomainMatcher_Match_Call) RunAndReturn(run func(host string) bool) *MockDomainMatcher_Match_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // NewMockStorage creates a new instance of MockStorage. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewMockStorage(t interface {
@@ -35,17 +267,74 @@ func (_m *MockStorage) EXPECT() *MockStorage_Expecter {
 	return &MockStorage_Expecter{mock: &_m.Mock}
 }
 
+// ConsumeClick provides a mock function for the type MockStorage
+func (_mock *MockStorage) ConsumeClick(ctx context.Context, alias string) error {
+	ret := _mock.Called(ctx, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ConsumeClick")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, alias)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStorage_ConsumeClick_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ConsumeClick'
+type MockStorage_ConsumeClick_Call struct {
+	*mock.Call
+}
+
+// ConsumeClick is a helper method to define mock.On call
+//   - ctx context.Context
+//   - alias string
+func (_e *MockStorage_Expecter) ConsumeClick(ctx interface{}, alias interface{}) *MockStorage_ConsumeClick_Call {
+	return &MockStorage_ConsumeClick_Call{Call: _e.mock.On("ConsumeClick", ctx, alias)}
+}
+
+func (_c *MockStorage_ConsumeClick_Call) Run(run func(ctx context.Context, alias string)) *MockStorage_ConsumeClick_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_ConsumeClick_Call) Return(err error) *MockStorage_ConsumeClick_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockStorage_ConsumeClick_Call) RunAndReturn(run func(ctx context.Context, alias string) error) *MockStorage_ConsumeClick_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // DeleteURL provides a mock function for the type MockStorage
-func (_mock *MockStorage) DeleteURL(alias string) error {
-	ret := _mock.Called(alias)
+func (_mock *MockStorage) DeleteURL(ctx context.Context, alias string) error {
+	ret := _mock.Called(ctx, alias)
 
 	if len(ret) == 0 {
 		panic("no return value specified for DeleteURL")
 	}
 
 	var r0 error
-	if returnFunc, ok := ret.Get(0).(func(string) error); ok {
-		r0 = returnFunc(alias)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = returnFunc(ctx, alias)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -58,19 +347,25 @@ type MockStorage_DeleteURL_Call struct {
 }
 
 // DeleteURL is a helper method to define mock.On call
+//   - ctx context.Context
 //   - alias string
-func (_e *MockStorage_Expecter) DeleteURL(alias interface{}) *MockStorage_DeleteURL_Call {
-	return &MockStorage_DeleteURL_Call{Call: _e.mock.On("DeleteURL", alias)}
+func (_e *MockStorage_Expecter) DeleteURL(ctx interface{}, alias interface{}) *MockStorage_DeleteURL_Call {
+	return &MockStorage_DeleteURL_Call{Call: _e.mock.On("DeleteURL", ctx, alias)}
 }
 
-func (_c *MockStorage_DeleteURL_Call) Run(run func(alias string)) *MockStorage_DeleteURL_Call {
+func (_c *MockStorage_DeleteURL_Call) Run(run func(ctx context.Context, alias string)) *MockStorage_DeleteURL_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 string
+		var arg0 context.Context
 		if args[0] != nil {
-			arg0 = args[0].(string)
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
 		}
 		run(
 			arg0,
+			arg1,
 		)
 	})
 	return _c
@@ -81,31 +376,163 @@ func (_c *MockStorage_DeleteURL_Call) Return(err error) *MockStorage_DeleteURL_C
 	return _c
 }
 
-func (_c *MockStorage_DeleteURL_Call) RunAndReturn(run func(alias string) error) *MockStorage_DeleteURL_Call {
+func (_c *MockStorage_DeleteURL_Call) RunAndReturn(run func(ctx context.Context, alias string) error) *MockStorage_DeleteURL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetLink provides a mock function for the type MockStorage
+func (_mock *MockStorage) GetLink(ctx context.Context, alias string) (storage.Link, error) {
+	ret := _mock.Called(ctx, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetLink")
+	}
+
+	var r0 storage.Link
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (storage.Link, error)); ok {
+		return returnFunc(ctx, alias)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) storage.Link); ok {
+		r0 = returnFunc(ctx, alias)
+	} else {
+		r0 = ret.Get(0).(storage.Link)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, alias)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStorage_GetLink_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetLink'
+type MockStorage_GetLink_Call struct {
+	*mock.Call
+}
+
+// GetLink is a helper method to define mock.On call
+//   - ctx context.Context
+//   - alias string
+func (_e *MockStorage_Expecter) GetLink(ctx interface{}, alias interface{}) *MockStorage_GetLink_Call {
+	return &MockStorage_GetLink_Call{Call: _e.mock.On("GetLink", ctx, alias)}
+}
+
+func (_c *MockStorage_GetLink_Call) Run(run func(ctx context.Context, alias string)) *MockStorage_GetLink_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_GetLink_Call) Return(link storage.Link, err error) *MockStorage_GetLink_Call {
+	_c.Call.Return(link, err)
+	return _c
+}
+
+func (_c *MockStorage_GetLink_Call) RunAndReturn(run func(ctx context.Context, alias string) (storage.Link, error)) *MockStorage_GetLink_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// GetStats provides a mock function for the type MockStorage
+func (_mock *MockStorage) GetStats(ctx context.Context, alias string) (storage.Stats, error) {
+	ret := _mock.Called(ctx, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetStats")
+	}
+
+	var r0 storage.Stats
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (storage.Stats, error)); ok {
+		return returnFunc(ctx, alias)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) storage.Stats); ok {
+		r0 = returnFunc(ctx, alias)
+	} else {
+		r0 = ret.Get(0).(storage.Stats)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, alias)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStorage_GetStats_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'GetStats'
+type MockStorage_GetStats_Call struct {
+	*mock.Call
+}
+
+// GetStats is a helper method to define mock.On call
+//   - ctx context.Context
+//   - alias string
+func (_e *MockStorage_Expecter) GetStats(ctx interface{}, alias interface{}) *MockStorage_GetStats_Call {
+	return &MockStorage_GetStats_Call{Call: _e.mock.On("GetStats", ctx, alias)}
+}
+
+func (_c *MockStorage_GetStats_Call) Run(run func(ctx context.Context, alias string)) *MockStorage_GetStats_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_GetStats_Call) Return(stats storage.Stats, err error) *MockStorage_GetStats_Call {
+	_c.Call.Return(stats, err)
+	return _c
+}
+
+func (_c *MockStorage_GetStats_Call) RunAndReturn(run func(ctx context.Context, alias string) (storage.Stats, error)) *MockStorage_GetStats_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
 // GetURL provides a mock function for the type MockStorage
-func (_mock *MockStorage) GetURL(alias string) (string, error) {
-	ret := _mock.Called(alias)
+func (_mock *MockStorage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	ret := _mock.Called(ctx, alias)
 
 	if len(ret) == 0 {
 		panic("no return value specified for GetURL")
 	}
 
-	var r0 string
+	var r0 storage.Link
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(string) (string, error)); ok {
-		return returnFunc(alias)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) (storage.Link, error)); ok {
+		return returnFunc(ctx, alias)
 	}
-	if returnFunc, ok := ret.Get(0).(func(string) string); ok {
-		r0 = returnFunc(alias)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string) storage.Link); ok {
+		r0 = returnFunc(ctx, alias)
 	} else {
-		r0 = ret.Get(0).(string)
+		r0 = ret.Get(0).(storage.Link)
 	}
-	if returnFunc, ok := ret.Get(1).(func(string) error); ok {
-		r1 = returnFunc(alias)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = returnFunc(ctx, alias)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -118,37 +545,189 @@ type MockStorage_GetURL_Call struct {
 }
 
 // GetURL is a helper method to define mock.On call
+//   - ctx context.Context
 //   - alias string
-func (_e *MockStorage_Expecter) GetURL(alias interface{}) *MockStorage_GetURL_Call {
-	return &MockStorage_GetURL_Call{Call: _e.mock.On("GetURL", alias)}
+func (_e *MockStorage_Expecter) GetURL(ctx interface{}, alias interface{}) *MockStorage_GetURL_Call {
+	return &MockStorage_GetURL_Call{Call: _e.mock.On("GetURL", ctx, alias)}
 }
 
-func (_c *MockStorage_GetURL_Call) Run(run func(alias string)) *MockStorage_GetURL_Call {
+func (_c *MockStorage_GetURL_Call) Run(run func(ctx context.Context, alias string)) *MockStorage_GetURL_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 string
+		var arg0 context.Context
 		if args[0] != nil {
-			arg0 = args[0].(string)
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
 		}
 		run(
 			arg0,
+			arg1,
 		)
 	})
 	return _c
 }
 
-func (_c *MockStorage_GetURL_Call) Return(s string, err error) *MockStorage_GetURL_Call {
-	_c.Call.Return(s, err)
+func (_c *MockStorage_GetURL_Call) Return(link storage.Link, err error) *MockStorage_GetURL_Call {
+	_c.Call.Return(link, err)
 	return _c
 }
 
-func (_c *MockStorage_GetURL_Call) RunAndReturn(run func(alias string) (string, error)) *MockStorage_GetURL_Call {
+func (_c *MockStorage_GetURL_Call) RunAndReturn(run func(ctx context.Context, alias string) (storage.Link, error)) *MockStorage_GetURL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// ListURLs provides a mock function for the type MockStorage
+func (_mock *MockStorage) ListURLs(ctx context.Context, params storage.ListParams) ([]storage.Link, error) {
+	ret := _mock.Called(ctx, params)
+
+	if len(ret) == 0 {
+		panic("no return value specified for ListURLs")
+	}
+
+	var r0 []storage.Link
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, storage.ListParams) ([]storage.Link, error)); ok {
+		return returnFunc(ctx, params)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, storage.ListParams) []storage.Link); ok {
+		r0 = returnFunc(ctx, params)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.Link)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, storage.ListParams) error); ok {
+		r1 = returnFunc(ctx, params)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStorage_ListURLs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'ListURLs'
+type MockStorage_ListURLs_Call struct {
+	*mock.Call
+}
+
+// ListURLs is a helper method to define mock.On call
+//   - ctx context.Context
+//   - params storage.ListParams
+func (_e *MockStorage_Expecter) ListURLs(ctx interface{}, params interface{}) *MockStorage_ListURLs_Call {
+	return &MockStorage_ListURLs_Call{Call: _e.mock.On("ListURLs", ctx, params)}
+}
+
+func (_c *MockStorage_ListURLs_Call) Run(run func(ctx context.Context, params storage.ListParams)) *MockStorage_ListURLs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 storage.ListParams
+		if args[1] != nil {
+			arg1 = args[1].(storage.ListParams)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_ListURLs_Call) Return(links []storage.Link, err error) *MockStorage_ListURLs_Call {
+	_c.Call.Return(links, err)
+	return _c
+}
+
+func (_c *MockStorage_ListURLs_Call) RunAndReturn(run func(ctx context.Context, params storage.ListParams) ([]storage.Link, error)) *MockStorage_ListURLs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SaveGeneratedURL provides a mock function for the type MockStorage
+func (_mock *MockStorage) SaveGeneratedURL(ctx context.Context, link storage.Link, alias storage.AliasFunc) (storage.Link, bool, error) {
+	ret := _mock.Called(ctx, link, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveGeneratedURL")
+	}
+
+	var r0 storage.Link
+	var r1 bool
+	var r2 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, storage.Link, storage.AliasFunc) (storage.Link, bool, error)); ok {
+		return returnFunc(ctx, link, alias)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, storage.Link, storage.AliasFunc) storage.Link); ok {
+		r0 = returnFunc(ctx, link, alias)
+	} else {
+		r0 = ret.Get(0).(storage.Link)
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, storage.Link, storage.AliasFunc) bool); ok {
+		r1 = returnFunc(ctx, link, alias)
+	} else {
+		r1 = ret.Get(1).(bool)
+	}
+	if returnFunc, ok := ret.Get(2).(func(context.Context, storage.Link, storage.AliasFunc) error); ok {
+		r2 = returnFunc(ctx, link, alias)
+	} else {
+		r2 = ret.Error(2)
+	}
+	return r0, r1, r2
+}
+
+// MockStorage_SaveGeneratedURL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveGeneratedURL'
+type MockStorage_SaveGeneratedURL_Call struct {
+	*mock.Call
+}
+
+// SaveGeneratedURL is a helper method to define mock.On call
+//   - ctx context.Context
+//   - link storage.Link
+//   - alias storage.AliasFunc
+func (_e *MockStorage_Expecter) SaveGeneratedURL(ctx interface{}, link interface{}, alias interface{}) *MockStorage_SaveGeneratedURL_Call {
+	return &MockStorage_SaveGeneratedURL_Call{Call: _e.mock.On("SaveGeneratedURL", ctx, link, alias)}
+}
+
+func (_c *MockStorage_SaveGeneratedURL_Call) Run(run func(ctx context.Context, link storage.Link, alias storage.AliasFunc)) *MockStorage_SaveGeneratedURL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 storage.Link
+		if args[1] != nil {
+			arg1 = args[1].(storage.Link)
+		}
+		var arg2 storage.AliasFunc
+		if args[2] != nil {
+			arg2 = args[2].(storage.AliasFunc)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_SaveGeneratedURL_Call) Return(link1 storage.Link, b bool, err error) *MockStorage_SaveGeneratedURL_Call {
+	_c.Call.Return(link1, b, err)
+	return _c
+}
+
+func (_c *MockStorage_SaveGeneratedURL_Call) RunAndReturn(run func(ctx context.Context, link storage.Link, alias storage.AliasFunc) (storage.Link, bool, error)) *MockStorage_SaveGeneratedURL_Call {
 	_c.Call.Return(run)
 	return _c
 }
 
 // SaveURL provides a mock function for the type MockStorage
-func (_mock *MockStorage) SaveURL(url string, alias string) (int64, error) {
-	ret := _mock.Called(url, alias)
+func (_mock *MockStorage) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
+	ret := _mock.Called(ctx, link)
 
 	if len(ret) == 0 {
 		panic("no return value specified for SaveURL")
@@ -156,16 +735,16 @@ func (_mock *MockStorage) SaveURL(url string, alias string) (int64, error) {
 
 	var r0 int64
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func(string, string) (int64, error)); ok {
-		return returnFunc(url, alias)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, storage.Link) (int64, error)); ok {
+		return returnFunc(ctx, link)
 	}
-	if returnFunc, ok := ret.Get(0).(func(string, string) int64); ok {
-		r0 = returnFunc(url, alias)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, storage.Link) int64); ok {
+		r0 = returnFunc(ctx, link)
 	} else {
 		r0 = ret.Get(0).(int64)
 	}
-	if returnFunc, ok := ret.Get(1).(func(string, string) error); ok {
-		r1 = returnFunc(url, alias)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, storage.Link) error); ok {
+		r1 = returnFunc(ctx, link)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -178,36 +757,236 @@ type MockStorage_SaveURL_Call struct {
 }
 
 // SaveURL is a helper method to define mock.On call
+//   - ctx context.Context
+//   - link storage.Link
+func (_e *MockStorage_Expecter) SaveURL(ctx interface{}, link interface{}) *MockStorage_SaveURL_Call {
+	return &MockStorage_SaveURL_Call{Call: _e.mock.On("SaveURL", ctx, link)}
+}
+
+func (_c *MockStorage_SaveURL_Call) Run(run func(ctx context.Context, link storage.Link)) *MockStorage_SaveURL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 storage.Link
+		if args[1] != nil {
+			arg1 = args[1].(storage.Link)
+		}
+		run(
+			arg0,
+			arg1,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_SaveURL_Call) Return(n int64, err error) *MockStorage_SaveURL_Call {
+	_c.Call.Return(n, err)
+	return _c
+}
+
+func (_c *MockStorage_SaveURL_Call) RunAndReturn(run func(ctx context.Context, link storage.Link) (int64, error)) *MockStorage_SaveURL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// SaveURLs provides a mock function for the type MockStorage
+func (_mock *MockStorage) SaveURLs(ctx context.Context, links []storage.Link, alias storage.AliasFunc) ([]storage.SaveResult, error) {
+	ret := _mock.Called(ctx, links, alias)
+
+	if len(ret) == 0 {
+		panic("no return value specified for SaveURLs")
+	}
+
+	var r0 []storage.SaveResult
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []storage.Link, storage.AliasFunc) ([]storage.SaveResult, error)); ok {
+		return returnFunc(ctx, links, alias)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []storage.Link, storage.AliasFunc) []storage.SaveResult); ok {
+		r0 = returnFunc(ctx, links, alias)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]storage.SaveResult)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []storage.Link, storage.AliasFunc) error); ok {
+		r1 = returnFunc(ctx, links, alias)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// MockStorage_SaveURLs_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'SaveURLs'
+type MockStorage_SaveURLs_Call struct {
+	*mock.Call
+}
+
+// SaveURLs is a helper method to define mock.On call
+//   - ctx context.Context
+//   - links []storage.Link
+//   - alias storage.AliasFunc
+func (_e *MockStorage_Expecter) SaveURLs(ctx interface{}, links interface{}, alias interface{}) *MockStorage_SaveURLs_Call {
+	return &MockStorage_SaveURLs_Call{Call: _e.mock.On("SaveURLs", ctx, links, alias)}
+}
+
+func (_c *MockStorage_SaveURLs_Call) Run(run func(ctx context.Context, links []storage.Link, alias storage.AliasFunc)) *MockStorage_SaveURLs_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 []storage.Link
+		if args[1] != nil {
+			arg1 = args[1].([]storage.Link)
+		}
+		var arg2 storage.AliasFunc
+		if args[2] != nil {
+			arg2 = args[2].(storage.AliasFunc)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_SaveURLs_Call) Return(saveResults []storage.SaveResult, err error) *MockStorage_SaveURLs_Call {
+	_c.Call.Return(saveResults, err)
+	return _c
+}
+
+func (_c *MockStorage_SaveURLs_Call) RunAndReturn(run func(ctx context.Context, links []storage.Link, alias storage.AliasFunc) ([]storage.SaveResult, error)) *MockStorage_SaveURLs_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateURL provides a mock function for the type MockStorage
+func (_mock *MockStorage) UpdateURL(ctx context.Context, alias string, url string) error {
+	ret := _mock.Called(ctx, alias, url)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateURL")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		r0 = returnFunc(ctx, alias, url)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStorage_UpdateURL_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateURL'
+type MockStorage_UpdateURL_Call struct {
+	*mock.Call
+}
+
+// UpdateURL is a helper method to define mock.On call
+//   - ctx context.Context
+//   - alias string
 //   - url string
+func (_e *MockStorage_Expecter) UpdateURL(ctx interface{}, alias interface{}, url interface{}) *MockStorage_UpdateURL_Call {
+	return &MockStorage_UpdateURL_Call{Call: _e.mock.On("UpdateURL", ctx, alias, url)}
+}
+
+func (_c *MockStorage_UpdateURL_Call) Run(run func(ctx context.Context, alias string, url string)) *MockStorage_UpdateURL_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 string
+		if args[1] != nil {
+			arg1 = args[1].(string)
+		}
+		var arg2 string
+		if args[2] != nil {
+			arg2 = args[2].(string)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *MockStorage_UpdateURL_Call) Return(err error) *MockStorage_UpdateURL_Call {
+	_c.Call.Return(err)
+	return _c
+}
+
+func (_c *MockStorage_UpdateURL_Call) RunAndReturn(run func(ctx context.Context, alias string, url string) error) *MockStorage_UpdateURL_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// UpdateUTM provides a mock function for the type MockStorage
+func (_mock *MockStorage) UpdateUTM(ctx context.Context, alias string, utm storage.UTM) error {
+	ret := _mock.Called(ctx, alias, utm)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpdateUTM")
+	}
+
+	var r0 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, string, storage.UTM) error); ok {
+		r0 = returnFunc(ctx, alias, utm)
+	} else {
+		r0 = ret.Error(0)
+	}
+	return r0
+}
+
+// MockStorage_UpdateUTM_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'UpdateUTM'
+type MockStorage_UpdateUTM_Call struct {
+	*mock.Call
+}
+
+// UpdateUTM is a helper method to define mock.On call
+//   - ctx context.Context
 //   - alias string
-func (_e *MockStorage_Expecter) SaveURL(url interface{}, alias interface{}) *MockStorage_SaveURL_Call {
-	return &MockStorage_SaveURL_Call{Call: _e.mock.On("SaveURL", url, alias)}
+//   - utm storage.UTM
+func (_e *MockStorage_Expecter) UpdateUTM(ctx interface{}, alias interface{}, utm interface{}) *MockStorage_UpdateUTM_Call {
+	return &MockStorage_UpdateUTM_Call{Call: _e.mock.On("UpdateUTM", ctx, alias, utm)}
 }
 
-func (_c *MockStorage_SaveURL_Call) Run(run func(url string, alias string)) *MockStorage_SaveURL_Call {
+func (_c *MockStorage_UpdateUTM_Call) Run(run func(ctx context.Context, alias string, utm storage.UTM)) *MockStorage_UpdateUTM_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 string
+		var arg0 context.Context
 		if args[0] != nil {
-			arg0 = args[0].(string)
+			arg0 = args[0].(context.Context)
 		}
 		var arg1 string
 		if args[1] != nil {
 			arg1 = args[1].(string)
 		}
+		var arg2 storage.UTM
+		if args[2] != nil {
+			arg2 = args[2].(storage.UTM)
+		}
 		run(
 			arg0,
 			arg1,
+			arg2,
 		)
 	})
 	return _c
 }
 
-func (_c *MockStorage_SaveURL_Call) Return(n int64, err error) *MockStorage_SaveURL_Call {
-	_c.Call.Return(n, err)
+func (_c *MockStorage_UpdateUTM_Call) Return(err error) *MockStorage_UpdateUTM_Call {
+	_c.Call.Return(err)
 	return _c
 }
 
-func (_c *MockStorage_SaveURL_Call) RunAndReturn(run func(url string, alias string) (int64, error)) *MockStorage_SaveURL_Call {
+func (_c *MockStorage_UpdateUTM_Call) RunAndReturn(run func(ctx context.Context, alias string, utm storage.UTM) error) *MockStorage_UpdateUTM_Call {
 	_c.Call.Return(run)
 	return _c
 }
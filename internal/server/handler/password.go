@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zulerne/url-shortener/internal/server/middleware"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Defaults of WithPasswordThrottle.
+const (
+	defaultPasswordMaxFailures = 5
+	defaultPasswordWindow      = 15 * time.Minute
+)
+
+// maxPasswordFormSize bounds the body of password form submissions.
+const maxPasswordFormSize = 4 << 10
+
+// WithPasswordThrottle allows maxFailures wrong passwords per protected link and window.
+// Further attempts are rejected until the window ends, even with the right password.
+// By default 5 wrong passwords per 15 minutes are allowed.
+func WithPasswordThrottle(maxFailures int, window time.Duration) Option {
+	return func(h *Handler) {
+		h.passwords = newThrottle(maxFailures, window)
+	}
+}
+
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Protected link</title>
+</head>
+<body>
+<form method="post">
+<p>This link is protected by a password.</p>
+{{if .}}<p role="alert">{{.}}</p>
+{{end}}<input type="password" name="password" aria-label="Password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// renderPasswordForm serves the form unlocking a protected link, with an optional message.
+// The form posts to the url it was served at, so the path and query of the request are kept.
+func renderPasswordForm(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	if err := passwordForm.Execute(w, msg); err != nil {
+		slog.Error("failed to render password form", "error", err)
+	}
+}
+
+// unlock redirects to the destination of a protected link if the form carries its password.
+// It answers with 303 See Other, so that the password is not posted on to the destination.
+func (h *Handler) unlock(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.unlock"
+
+	log := slog.With(
+		"op", op,
+		string(middleware.RequestIDKey), middleware.GetRequestID(r.Context()),
+	)
+
+	alias, link, ok := h.lookupLink(w, r, log)
+	if !ok {
+		return
+	}
+
+	if link.PasswordHash == "" {
+		h.follow(w, r, log, alias, link, http.StatusSeeOther)
+		return
+	}
+
+	if wait := h.passwords.acquire(alias, h.now()); wait > 0 {
+		log.Warn("too many wrong passwords", "alias", alias)
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		renderPasswordForm(w, http.StatusTooManyRequests, "Too many wrong passwords, try again later.")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPasswordFormSize)
+	err := bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(r.PostFormValue("password")))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		log.Info("wrong password", "alias", alias)
+		renderPasswordForm(w, http.StatusForbidden, "Wrong password.")
+		return
+	}
+
+	// Only wrong passwords count
+	h.passwords.release(alias)
+
+	if err != nil {
+		msg := "failed to check password"
+		log.Error(msg, "error", err)
+		renderPasswordForm(w, http.StatusInternalServerError, "Something went wrong, try again later.")
+		return
+	}
+
+	h.follow(w, r, log, alias, link, http.StatusSeeOther)
+}
+
+// throttle limits failed attempts per key in fixed windows. Attempts are counted
+// before they are checked, so that concurrent ones cannot exceed the limit.
+type throttle struct {
+	maxFailures int
+	window      time.Duration
+
+	mu       sync.Mutex
+	failures map[string]failureWindow
+	pruned   time.Time
+}
+
+type failureWindow struct {
+	start time.Time
+	count int
+}
+
+func newThrottle(maxFailures int, window time.Duration) *throttle {
+	return &throttle{
+		maxFailures: maxFailures,
+		window:      window,
+		failures:    make(map[string]failureWindow),
+	}
+}
+
+// acquire counts an attempt on key. It returns zero if the attempt may go on,
+// otherwise how long attempts on key are blocked. Successful attempts are given back with release.
+func (t *throttle) acquire(key string, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Forget ended windows once per window, so that the map does not grow forever
+	if now.Sub(t.pruned) >= t.window {
+		for k, f := range t.failures {
+			if !now.Before(f.start.Add(t.window)) {
+				delete(t.failures, k)
+			}
+		}
+		t.pruned = now
+	}
+
+	f, ok := t.failures[key]
+	if !ok || !now.Before(f.start.Add(t.window)) {
+		f = failureWindow{start: now}
+	}
+	if f.count >= t.maxFailures {
+		return f.start.Add(t.window).Sub(now)
+	}
+
+	f.count++
+	t.failures[key] = f
+
+	return 0
+}
+
+// release takes back an attempt on key counted by acquire.
+func (t *throttle) release(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if f, ok := t.failures[key]; ok && f.count > 0 {
+		f.count--
+		t.failures[key] = f
+	}
+}
@@ -0,0 +1,196 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func protectedLink(t *testing.T, password string) storage.Link {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	return storage.Link{URL: "https://example.com/docs", Prefix: true, ForwardQuery: true, PasswordHash: string(hash)}
+}
+
+func postPassword(h http.Handler, path string, password string) *httptest.ResponseRecorder {
+	form := url.Values{"password": {password}}
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	return w
+}
+
+func TestProtectedLinkForm(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(protectedLink(t, "secret"), nil).Once()
+
+	// The form is no click
+	h := handler.NewHandler(storageMock, 6, "", "", handler.WithClickRecorder(NewMockClickRecorder(t)))
+
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	require.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+	require.Empty(t, w.Header().Get("Location"))
+	require.Contains(t, w.Body.String(), `<form method="post">`)
+	require.Contains(t, w.Body.String(), `name="password"`)
+}
+
+func TestProtectedLinkUnlock(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	link := protectedLink(t, "secret")
+
+	cases := []struct {
+		name     string
+		path     string
+		password string
+		code     int
+		location string
+		body     string
+	}{
+		{
+			name:     "Right Password",
+			path:     "/abc",
+			password: "secret",
+			code:     http.StatusSeeOther,
+			location: "https://example.com/docs",
+		},
+		{
+			name:     "Path And Query Kept",
+			path:     "/abc/guide?page=2",
+			password: "secret",
+			code:     http.StatusSeeOther,
+			location: "https://example.com/docs/guide?page=2",
+		},
+		{
+			name:     "Wrong Password",
+			path:     "/abc",
+			password: "guess",
+			code:     http.StatusForbidden,
+			body:     "Wrong password.",
+		},
+		{
+			name:     "Empty Password",
+			path:     "/abc",
+			password: "",
+			code:     http.StatusForbidden,
+			body:     "Wrong password.",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(link, nil).Once()
+
+			recorderMock := NewMockClickRecorder(t)
+			if tc.location != "" {
+				recorderMock.EXPECT().Record(mock.Anything).Return().Once()
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "", handler.WithClickRecorder(recorderMock))
+
+			w := postPassword(h, tc.path, tc.password)
+
+			require.Equal(t, tc.code, w.Code)
+			require.Equal(t, tc.location, w.Header().Get("Location"))
+			require.Contains(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestProtectedLinkThrottle(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(protectedLink(t, "secret"), nil)
+	storageMock.EXPECT().GetURL(mock.Anything, "def").Return(protectedLink(t, "secret"), nil)
+
+	h := handler.NewHandler(storageMock, 6, "", "", handler.WithPasswordThrottle(2, time.Hour))
+
+	// Right passwords do not count
+	require.Equal(t, http.StatusSeeOther, postPassword(h, "/abc", "secret").Code)
+	require.Equal(t, http.StatusForbidden, postPassword(h, "/abc", "guess").Code)
+	require.Equal(t, http.StatusSeeOther, postPassword(h, "/abc", "secret").Code)
+	require.Equal(t, http.StatusForbidden, postPassword(h, "/abc", "guess").Code)
+
+	w := postPassword(h, "/abc", "secret")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	// Other links are not affected
+	require.Equal(t, http.StatusSeeOther, postPassword(h, "/def", "secret").Code)
+}
+
+func TestCreateURLHandlerPassword(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	cases := []struct {
+		name      string
+		password  string
+		respError string
+	}{
+		{name: "Success", password: "secret"},
+		{name: "Too Long", password: strings.Repeat("ä", 40), respError: "'Password' must be at most 72 bytes long"},
+		{name: "Too Many Characters", password: strings.Repeat("a", 73), respError: "'Password' must be at most 72 characters long"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.respError == "" {
+				// Protected links are not deduplicated
+				storageMock.EXPECT().
+					SaveGeneratedURL(mock.Anything, mock.MatchedBy(func(link storage.Link) bool {
+						return link.NormalizedURL == "" &&
+							bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(tc.password)) == nil
+					}), mock.Anything).
+					Return(storage.Link{ID: 1, Alias: "abc123"}, true, nil).
+					Once()
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "", handler.WithDedup(true))
+
+			body, _ := json.Marshal(map[string]any{"url": "https://example.com/", "password": tc.password})
+			req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+			req.SetBasicAuth("", "")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			var resp handler.CreateURLResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+		})
+	}
+}
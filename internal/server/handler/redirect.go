@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zulerne/url-shortener/internal/server/response"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+// permanentRedirectMaxAge is how long clients may cache permanent redirects.
+const permanentRedirectMaxAge = 24 * time.Hour
+
+// WithRedirectType sets the http status of redirects of links created without one.
+// It must be 301, 302, 307 or 308, by default it is 307.
+func WithRedirectType(status int) Option {
+	return func(h *Handler) {
+		h.redirectType = status
+	}
+}
+
+// WithNotYetAvailablePage sets the HTML page served with 404 Not Found for links before their
+// ValidFrom. By default they are answered like missing links.
+func WithNotYetAvailablePage(page []byte) Option {
+	return func(h *Handler) {
+		h.notYetAvailable = page
+	}
+}
+
+// renderNotYetAvailable answers requests for links before their ValidFrom.
+// The answer changes on activation, so it must not be cached.
+func (h *Handler) renderNotYetAvailable(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if h.notYetAvailable == nil {
+		h.renderJSON(w, http.StatusNotFound, response.Error(storage.ErrNotFound.Error()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(h.notYetAvailable)
+}
+
+// redirectStatus returns the http status link redirects with.
+func (h *Handler) redirectStatus(link storage.Link) int {
+	if link.RedirectType != 0 {
+		return link.RedirectType
+	}
+	return h.redirectType
+}
+
+// setCacheControl lets clients cache permanent redirects, but not beyond the expiry of link.
+// Temporary redirects and links with MaxClicks are not cached, so that every click reaches the service.
+func setCacheControl(w http.ResponseWriter, status int, link storage.Link, now time.Time) {
+	permanent := status == http.StatusMovedPermanently || status == http.StatusPermanentRedirect
+	if !permanent || link.MaxClicks > 0 {
+		w.Header().Set("Cache-Control", "private, no-cache")
+		return
+	}
+
+	maxAge := permanentRedirectMaxAge
+	if !link.ExpiresAt.IsZero() {
+		maxAge = min(maxAge, link.ExpiresAt.Sub(now))
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds())))
+}
+
+// destination returns the url link redirects to for a request with the escaped path rest
+// after the alias and the given query. rest is appended to the path of prefix links,
+// other links are storage.ErrNotFound for it. The UTM tags of link replace parameters
+// of its url. Links forwarding the query get the parameters of query that are not set
+// already, so that clients cannot override them.
+func destination(link storage.Link, rest string, query url.Values) (string, error) {
+	if rest != "" && !link.Prefix {
+		return "", storage.ErrNotFound
+	}
+
+	forward := link.ForwardQuery && len(query) > 0
+	tagged := link.UTM != storage.UTM{}
+	if rest == "" && !forward && !tagged {
+		return link.URL, nil
+	}
+
+	u, err := url.Parse(link.URL)
+	if err != nil {
+		return "", err
+	}
+	if rest != "" {
+		u = u.JoinPath(rest)
+	}
+	if !forward && !tagged {
+		return u.String(), nil
+	}
+
+	params := u.Query()
+	for key, values := range utmParams(link.UTM) {
+		params[key] = values
+	}
+	if forward {
+		for key, values := range query {
+			if _, ok := params[key]; !ok {
+				params[key] = values
+			}
+		}
+	}
+	u.RawQuery = params.Encode()
+
+	return u.String(), nil
+}
+
+// restPath returns the escaped path after the alias of a redirect request.
+func restPath(r *http.Request) string {
+	_, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	return rest
+}
@@ -0,0 +1,613 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+func TestRedirectType(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	cases := []struct {
+		name         string
+		link         storage.Link
+		defaultType  int
+		code         int
+		cacheControl string
+	}{
+		{
+			name:         "Default",
+			link:         storage.Link{URL: "https://google.com/"},
+			code:         http.StatusTemporaryRedirect,
+			cacheControl: "private, no-cache",
+		},
+		{
+			name:         "Configured Default",
+			link:         storage.Link{URL: "https://google.com/"},
+			defaultType:  http.StatusFound,
+			code:         http.StatusFound,
+			cacheControl: "private, no-cache",
+		},
+		{
+			name:         "Moved Permanently",
+			link:         storage.Link{URL: "https://google.com/", RedirectType: http.StatusMovedPermanently},
+			defaultType:  http.StatusFound,
+			code:         http.StatusMovedPermanently,
+			cacheControl: "public, max-age=86400",
+		},
+		{
+			name:         "Permanent Redirect",
+			link:         storage.Link{URL: "https://google.com/", RedirectType: http.StatusPermanentRedirect},
+			code:         http.StatusPermanentRedirect,
+			cacheControl: "public, max-age=86400",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(tc.link, nil).Once()
+
+			var opts []handler.Option
+			if tc.defaultType != 0 {
+				opts = append(opts, handler.WithRedirectType(tc.defaultType))
+			}
+			h := handler.NewHandler(storageMock, 6, "", "", opts...)
+
+			req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+			require.Equal(t, tc.link.URL, w.Header().Get("Location"))
+			require.Equal(t, tc.cacheControl, w.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestRedirectTypeExpiring(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().
+		GetURL(mock.Anything, "abc").
+		Return(storage.Link{
+			URL:          "https://google.com/",
+			ExpiresAt:    time.Now().Add(time.Hour),
+			RedirectType: http.StatusMovedPermanently,
+		}, nil).
+		Once()
+
+	h := handler.NewHandler(storageMock, 6, "", "")
+
+	req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusMovedPermanently, w.Code)
+
+	// Permanent redirects must not be cached beyond the expiry
+	maxAge, ok := strings.CutPrefix(w.Header().Get("Cache-Control"), "public, max-age=")
+	require.True(t, ok)
+	seconds, err := strconv.Atoi(maxAge)
+	require.NoError(t, err)
+	require.InDelta(t, 3600, seconds, 5)
+}
+
+func TestCreateURLHandlerRedirectType(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	cases := []struct {
+		name         string
+		redirectType int
+		respError    string
+	}{
+		{name: "Permanent", redirectType: http.StatusPermanentRedirect},
+		{name: "Found", redirectType: http.StatusFound},
+		{name: "See Other", redirectType: http.StatusSeeOther, respError: "'RedirectType' must be one of 301, 302, 307, 308"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.respError == "" {
+				storageMock.EXPECT().
+					SaveURL(mock.Anything, storage.Link{Alias: "abc", URL: "https://google.com/", RedirectType: tc.redirectType}).
+					Return(1, nil).
+					Once()
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "")
+
+			body, _ := json.Marshal(map[string]any{
+				"url":           "https://google.com/",
+				"alias":         "abc",
+				"redirect_type": tc.redirectType,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+			req.SetBasicAuth("", "")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			var resp handler.CreateURLResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+		})
+	}
+}
+
+func TestRedirectPassthrough(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	cases := []struct {
+		name     string
+		link     storage.Link
+		path     string
+		code     int
+		location string
+	}{
+		{
+			name:     "Query Dropped",
+			link:     storage.Link{URL: "https://google.com/"},
+			path:     "/abc?ref=newsletter",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://google.com/",
+		},
+		{
+			name:     "Query Forwarded",
+			link:     storage.Link{URL: "https://google.com/", ForwardQuery: true},
+			path:     "/abc?ref=newsletter&ref=mail",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://google.com/?ref=newsletter&ref=mail",
+		},
+		{
+			name:     "Destination Parameters Win",
+			link:     storage.Link{URL: "https://google.com/search?q=go", ForwardQuery: true},
+			path:     "/abc?q=rust&ref=newsletter",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://google.com/search?q=go&ref=newsletter",
+		},
+		{
+			name:     "Prefix",
+			link:     storage.Link{URL: "https://example.com/docs", Prefix: true},
+			path:     "/abc/guide/intro",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://example.com/docs/guide/intro",
+		},
+		{
+			name:     "Prefix Trailing Slash",
+			link:     storage.Link{URL: "https://example.com/docs/", Prefix: true},
+			path:     "/abc/guide/",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://example.com/docs/guide/",
+		},
+		{
+			name:     "Prefix Escaped",
+			link:     storage.Link{URL: "https://example.com/docs", Prefix: true},
+			path:     "/abc/a%2Fb%20c",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://example.com/docs/a%2Fb%20c",
+		},
+		{
+			name:     "Prefix Without Path",
+			link:     storage.Link{URL: "https://example.com/docs?lang=en", Prefix: true},
+			path:     "/abc",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://example.com/docs?lang=en",
+		},
+		{
+			name:     "Prefix And Query",
+			link:     storage.Link{URL: "https://example.com/docs?lang=en", Prefix: true, ForwardQuery: true},
+			path:     "/abc/guide?page=2&lang=de",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://example.com/docs/guide?lang=en&page=2",
+		},
+		{
+			name: "Path On Plain Link",
+			link: storage.Link{URL: "https://example.com/docs"},
+			path: "/abc/guide",
+			code: http.StatusNotFound,
+		},
+		{
+			name:     "Trailing Slash On Plain Link",
+			link:     storage.Link{URL: "https://example.com/docs"},
+			path:     "/abc/",
+			code:     http.StatusTemporaryRedirect,
+			location: "https://example.com/docs",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(tc.link, nil).Once()
+
+			h := handler.NewHandler(storageMock, 6, "", "")
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+			require.Equal(t, tc.location, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestRedirectMaxClicks(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	cases := []struct {
+		name         string
+		link         storage.Link
+		getErr       error
+		consumeErr   error
+		consume      bool
+		code         int
+		cacheControl string
+	}{
+		{
+			name:         "Click Left",
+			link:         storage.Link{URL: "https://google.com/", MaxClicks: 1, RedirectType: http.StatusPermanentRedirect},
+			consume:      true,
+			code:         http.StatusPermanentRedirect,
+			cacheControl: "private, no-cache",
+		},
+		{
+			name:       "Exhausted Concurrently",
+			link:       storage.Link{URL: "https://google.com/", MaxClicks: 1},
+			consume:    true,
+			consumeErr: storage.ErrExhausted,
+			code:       http.StatusGone,
+		},
+		{
+			name:       "Deleted Concurrently",
+			link:       storage.Link{URL: "https://google.com/", MaxClicks: 1},
+			consume:    true,
+			consumeErr: storage.ErrNotFound,
+			code:       http.StatusNotFound,
+		},
+		{
+			name:       "Storage Error",
+			link:       storage.Link{URL: "https://google.com/", MaxClicks: 1},
+			consume:    true,
+			consumeErr: errors.New("unexpected db error"),
+			code:       http.StatusInternalServerError,
+		},
+		{
+			name:   "Exhausted",
+			getErr: storage.ErrExhausted,
+			code:   http.StatusGone,
+		},
+		{
+			name:         "Unlimited",
+			link:         storage.Link{URL: "https://google.com/"},
+			code:         http.StatusTemporaryRedirect,
+			cacheControl: "private, no-cache",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(tc.link, tc.getErr).Once()
+			if tc.consume {
+				storageMock.EXPECT().ConsumeClick(mock.Anything, "abc").Return(tc.consumeErr).Once()
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "")
+
+			req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+			require.Equal(t, tc.cacheControl, w.Header().Get("Cache-Control"))
+		})
+	}
+}
+
+func TestRedirectMaxClicksHead(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	link := storage.Link{URL: "https://google.com/", MaxClicks: 1}
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(link, nil).Twice()
+	// Only the GET takes the click
+	storageMock.EXPECT().ConsumeClick(mock.Anything, "abc").Return(nil).Once()
+
+	recorderMock := NewMockClickRecorder(t)
+	recorderMock.EXPECT().Record(mock.Anything).Return().Once()
+
+	h := handler.NewHandler(storageMock, 6, "", "", handler.WithClickRecorder(recorderMock))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/abc", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get("Location"))
+	require.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/abc", nil))
+
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	require.Equal(t, "https://google.com/", w.Header().Get("Location"))
+}
+
+func TestRedirectHead(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(storage.Link{URL: "https://google.com/"}, nil).Once()
+
+	// HEAD requests are no clicks
+	h := handler.NewHandler(storageMock, 6, "", "", handler.WithClickRecorder(NewMockClickRecorder(t)))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/abc", nil))
+
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+	require.Equal(t, "https://google.com/", w.Header().Get("Location"))
+}
+
+func TestCreateURLHandlerMaxClicks(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	cases := []struct {
+		name      string
+		maxClicks int64
+		respError string
+	}{
+		{name: "Once", maxClicks: 1},
+		{name: "Many", maxClicks: 1000},
+		{name: "Negative", maxClicks: -1, respError: "'MaxClicks' must be at least 1"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.respError == "" {
+				// Limited links are not deduplicated
+				storageMock.EXPECT().
+					SaveGeneratedURL(mock.Anything, storage.Link{URL: "https://google.com/", MaxClicks: tc.maxClicks}, mock.Anything).
+					Return(storage.Link{ID: 1, Alias: "abc123"}, true, nil).
+					Once()
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "", handler.WithDedup(true))
+
+			body, _ := json.Marshal(map[string]any{
+				"url":        "https://google.com/",
+				"max_clicks": tc.maxClicks,
+			})
+			req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+			req.SetBasicAuth("", "")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			var resp handler.CreateURLResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+		})
+	}
+}
+
+func TestRedirectValidFrom(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	link := storage.Link{URL: "https://google.com/", ValidFrom: now, ExpiresAt: now.Add(time.Hour)}
+	page := []byte("<p>Coming soon</p>")
+
+	cases := []struct {
+		name         string
+		clock        time.Time
+		page         []byte
+		code         int
+		location     string
+		contentType  string
+		cacheControl string
+		body         string
+	}{
+		{
+			name:         "Before",
+			clock:        now.Add(-time.Second),
+			code:         http.StatusNotFound,
+			contentType:  "application/json",
+			cacheControl: "no-store",
+			body:         storage.ErrNotFound.Error(),
+		},
+		{
+			name:         "Before With Page",
+			clock:        now.Add(-time.Second),
+			page:         page,
+			code:         http.StatusNotFound,
+			contentType:  "text/html; charset=utf-8",
+			cacheControl: "no-store",
+			body:         string(page),
+		},
+		{
+			name:         "At",
+			clock:        now,
+			page:         page,
+			code:         http.StatusTemporaryRedirect,
+			location:     "https://google.com/",
+			cacheControl: "private, no-cache",
+		},
+		{
+			name:        "After End",
+			clock:       now.Add(time.Hour),
+			code:        http.StatusGone,
+			contentType: "application/json",
+			body:        storage.ErrExpired.Error(),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(link, nil).Once()
+
+			// Only redirects are clicks
+			recorderMock := NewMockClickRecorder(t)
+			if tc.location != "" {
+				recorderMock.EXPECT().
+					Record(mock.MatchedBy(func(click storage.Click) bool { return click.At.Equal(tc.clock) })).
+					Return().
+					Once()
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "",
+				handler.WithClickRecorder(recorderMock),
+				handler.WithNotYetAvailablePage(tc.page),
+				handler.WithClock(func() time.Time { return tc.clock }),
+			)
+
+			req := httptest.NewRequest(http.MethodGet, "/abc", nil)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+			require.Equal(t, tc.location, w.Header().Get("Location"))
+			require.Equal(t, tc.cacheControl, w.Header().Get("Cache-Control"))
+			if tc.contentType != "" {
+				require.Equal(t, tc.contentType, w.Header().Get("Content-Type"))
+			}
+			require.Contains(t, w.Body.String(), tc.body)
+		})
+	}
+}
+
+func TestCreateURLHandlerValidFrom(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(24 * time.Hour)
+	past := now.Add(-24 * time.Hour)
+	beforeFuture := future.Add(-time.Minute)
+
+	cases := []struct {
+		name      string
+		body      map[string]any
+		link      storage.Link
+		respError string
+	}{
+		{
+			name: "Scheduled",
+			body: map[string]any{"valid_from": future},
+			link: storage.Link{URL: "https://google.com/", ValidFrom: future},
+		},
+		{
+			name: "Scheduled Window",
+			body: map[string]any{"valid_from": future, "expires_at": future.Add(time.Hour)},
+			link: storage.Link{URL: "https://google.com/", ValidFrom: future, ExpiresAt: future.Add(time.Hour)},
+		},
+		{
+			name: "TTL Counts From Valid From",
+			body: map[string]any{"valid_from": future, "ttl": "1h"},
+			link: storage.Link{URL: "https://google.com/", ValidFrom: future, ExpiresAt: future.Add(time.Hour)},
+		},
+		{
+			name: "TTL Counts From Now If Valid Already",
+			body: map[string]any{"valid_from": past, "ttl": "1h"},
+			link: storage.Link{URL: "https://google.com/", ValidFrom: past, ExpiresAt: now.Add(time.Hour)},
+		},
+		{
+			name: "Valid Until",
+			body: map[string]any{"valid_from": future, "valid_until": future.Add(time.Hour)},
+			link: storage.Link{URL: "https://google.com/", ValidFrom: future, ExpiresAt: future.Add(time.Hour)},
+		},
+		{
+			name:      "Valid Until Before Valid From",
+			body:      map[string]any{"valid_from": future, "valid_until": beforeFuture},
+			respError: "'ValidUntil' must be after 'ValidFrom'",
+		},
+		{
+			name:      "Valid Until And Expires At",
+			body:      map[string]any{"valid_until": future, "expires_at": future},
+			respError: "'ValidUntil' cannot be used together with 'ExpiresAt'",
+		},
+		{
+			name:      "Valid Until And TTL",
+			body:      map[string]any{"valid_until": future, "ttl": "1h"},
+			respError: "'ValidUntil' cannot be used together with 'TTL'",
+		},
+		{
+			name:      "Ends Before Valid From",
+			body:      map[string]any{"valid_from": future, "expires_at": beforeFuture},
+			respError: "'ExpiresAt' must be after 'ValidFrom'",
+		},
+		{
+			name:      "Ends In The Past",
+			body:      map[string]any{"valid_from": past, "expires_at": past.Add(time.Hour)},
+			respError: "'ExpiresAt' must be in the future",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.respError == "" {
+				// Scheduled links are not deduplicated
+				storageMock.EXPECT().
+					SaveGeneratedURL(mock.Anything, tc.link, mock.Anything).
+					Return(storage.Link{ID: 1, Alias: "abc123"}, true, nil).
+					Once()
+			}
+
+			h := handler.NewHandler(storageMock, 6, "", "",
+				handler.WithDedup(true),
+				handler.WithClock(func() time.Time { return now }),
+			)
+
+			tc.body["url"] = "https://google.com/"
+			body, _ := json.Marshal(tc.body)
+			req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+			req.SetBasicAuth("", "")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			var resp handler.CreateURLResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+		})
+	}
+}
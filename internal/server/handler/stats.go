@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/zulerne/url-shortener/internal/server/middleware"
+	"github.com/zulerne/url-shortener/internal/server/response"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+type StatsResponse struct {
+	response.Response
+	Alias string        `json:"alias"`
+	Total int64         `json:"total"`
+	Daily []DailyClicks `json:"daily"`
+}
+
+type DailyClicks struct {
+	Day    string `json:"day"`
+	Clicks int64  `json:"clicks"`
+}
+
+func (h *Handler) urlStats(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.urlStats"
+
+	log := slog.With(
+		"op", op,
+		string(middleware.RequestIDKey), middleware.GetRequestID(r.Context()),
+	)
+
+	alias := h.pathAlias(r)
+
+	stats, err := h.storage.GetStats(r.Context(), alias)
+	if err != nil {
+		msg := "failed to get stats"
+		log.Error(msg, "error", err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.renderJSON(w, http.StatusNotFound, response.Error(storage.ErrNotFound.Error()))
+			return
+		}
+
+		h.renderJSON(w, http.StatusInternalServerError, response.Error(msg))
+		return
+	}
+
+	daily := make([]DailyClicks, 0, len(stats.Daily))
+	for _, d := range stats.Daily {
+		daily = append(daily, DailyClicks{Day: d.Day, Clicks: d.Clicks})
+	}
+
+	h.renderJSON(w, http.StatusOK, StatsResponse{
+		Response: response.Ok(),
+		Alias:    alias,
+		Total:    stats.Total,
+		Daily:    daily,
+	})
+}
@@ -0,0 +1,178 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+func TestURLStatsHandler(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	user := "user"
+	pass := "pass"
+
+	cases := []struct {
+		name      string
+		code      int
+		alias     string
+		pass      string
+		respError string
+		resp      handler.StatsResponse
+		mockSetup func(s *MockStorage)
+	}{
+		{
+			name:  "Success",
+			code:  http.StatusOK,
+			alias: "test_alias",
+			pass:  pass,
+			resp: handler.StatsResponse{
+				Alias: "test_alias",
+				Total: 3,
+				Daily: []handler.DailyClicks{
+					{Day: "2025-03-01", Clicks: 2},
+					{Day: "2025-03-02", Clicks: 1},
+				},
+			},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					GetStats(mock.Anything, "test_alias").
+					Return(storage.Stats{
+						Total: 3,
+						Daily: []storage.DailyClicks{
+							{Day: "2025-03-01", Clicks: 2},
+							{Day: "2025-03-02", Clicks: 1},
+						},
+					}, nil).
+					Once()
+			},
+		},
+		{
+			name:  "No Clicks",
+			code:  http.StatusOK,
+			alias: "test_alias",
+			pass:  pass,
+			resp: handler.StatsResponse{
+				Alias: "test_alias",
+				Daily: []handler.DailyClicks{},
+			},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					GetStats(mock.Anything, "test_alias").
+					Return(storage.Stats{}, nil).
+					Once()
+			},
+		},
+		{
+			name:      "NotFound",
+			code:      http.StatusNotFound,
+			alias:     "not_found",
+			pass:      pass,
+			respError: storage.ErrNotFound.Error(),
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					GetStats(mock.Anything, "not_found").
+					Return(storage.Stats{}, storage.ErrNotFound).
+					Once()
+			},
+		},
+		{
+			name:      "GetStats Internal Error",
+			code:      http.StatusInternalServerError,
+			alias:     "fail",
+			pass:      pass,
+			respError: "failed to get stats",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					GetStats(mock.Anything, "fail").
+					Return(storage.Stats{}, errors.New("unexpected db error")).
+					Once()
+			},
+		},
+		{
+			name:  "Unauthorized",
+			code:  http.StatusUnauthorized,
+			alias: "test_alias",
+			pass:  "wrong_pass",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.mockSetup != nil {
+				tc.mockSetup(storageMock)
+			}
+
+			h := handler.NewHandler(storageMock, 6, user, pass)
+
+			req := httptest.NewRequest(http.MethodGet, "/url/"+tc.alias+"/stats", nil)
+			req.SetBasicAuth(user, tc.pass)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+
+			if tc.code == http.StatusUnauthorized {
+				return
+			}
+
+			var resp handler.StatsResponse
+			err := json.Unmarshal(w.Body.Bytes(), &resp)
+			require.NoError(t, err)
+			require.Equal(t, tc.respError, resp.Error)
+
+			if tc.respError == "" {
+				require.Equal(t, tc.resp.Alias, resp.Alias)
+				require.Equal(t, tc.resp.Total, resp.Total)
+				require.Equal(t, tc.resp.Daily, resp.Daily)
+			}
+		})
+	}
+}
+
+func TestRedirectRecordsClick(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().
+		GetURL(mock.Anything, "test_alias").
+		Return(storage.Link{URL: "https://google.com"}, nil).
+		Once()
+
+	recorderMock := NewMockClickRecorder(t)
+	recorderMock.EXPECT().
+		Record(mock.MatchedBy(func(click storage.Click) bool {
+			return click.Alias == "test_alias" &&
+				click.Referrer == "https://example.com" &&
+				click.UserAgent == "test-agent" &&
+				click.RequestID == "request-1" &&
+				!click.At.IsZero()
+		})).
+		Return().
+		Once()
+
+	h := handler.NewHandler(storageMock, 6, "", "", handler.WithClickRecorder(recorderMock))
+
+	req := httptest.NewRequest(http.MethodGet, "/test_alias", nil)
+	req.Header.Set("Referer", "https://example.com")
+	req.Header.Set("User-Agent", "test-agent")
+	req.Header.Set("X-Request-ID", "request-1")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+}
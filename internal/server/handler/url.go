@@ -1,26 +1,64 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-playground/validator/v10"
-	"github.com/zulerne/url-shortener/internal/lib/random"
 	"github.com/zulerne/url-shortener/internal/server/middleware"
 	"github.com/zulerne/url-shortener/internal/server/response"
 	"github.com/zulerne/url-shortener/internal/storage"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type CreateURLRequest struct {
 	URL   string `json:"url" validate:"required,url"`
-	Alias string `json:"alias,omitempty"`
+	Alias string `json:"alias,omitempty" validate:"omitempty,alias"`
+	// ValidFrom delays the first redirect, before it the link is not available.
+	ValidFrom *time.Time `json:"valid_from,omitempty"`
+	// ExpiresAt and TTL (e.g. "24h") are mutually exclusive ways to limit the link lifetime.
+	// TTL counts from ValidFrom if that is in the future.
+	ExpiresAt *time.Time `json:"expires_at,omitempty" validate:"omitempty,excluded_with=TTL"`
+	TTL       string     `json:"ttl,omitempty"`
+	// ValidUntil is another name of ExpiresAt, closing the window opened by ValidFrom.
+	ValidUntil *time.Time `json:"valid_until,omitempty" validate:"omitempty,excluded_with=ExpiresAt,excluded_with=TTL"`
+	// RedirectType is the http status of redirects, the service default if omitted.
+	RedirectType int `json:"redirect_type,omitempty" validate:"omitempty,oneof=301 302 307 308"`
+	// ForwardQuery passes the query of redirect requests on to the destination.
+	ForwardQuery bool `json:"forward_query,omitempty"`
+	// Prefix makes /{alias}/rest redirect to the url with rest appended to its path.
+	Prefix bool `json:"prefix,omitempty"`
+	UTM    UTM  `json:"utm,omitzero"`
+	// Password protects the link, it is asked for by a form before redirecting.
+	Password string `json:"password,omitempty" validate:"omitempty,max=72"`
+	// MaxClicks limits how many times the link redirects, after that it is gone.
+	MaxClicks int64 `json:"max_clicks,omitempty" validate:"omitempty,min=1"`
+}
+
+// LogValue keeps the password out of logs.
+func (req CreateURLRequest) LogValue() slog.Value {
+	type request CreateURLRequest
+	if req.Password != "" {
+		req.Password = "[REDACTED]"
+	}
+	return slog.AnyValue(request(req))
+}
+
+type UpdateURLRequest struct {
+	URL string `json:"url" validate:"required,url"`
 }
 
 type CreateURLResponse struct {
 	response.Response
-	Alias string `json:"alias,omitempty"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Reused is set if an existing link to the same destination was returned.
+	Reused bool `json:"reused"`
 }
 
 func (h *Handler) createURL(w http.ResponseWriter, r *http.Request) {
@@ -39,6 +77,335 @@ func (h *Handler) createURL(w http.ResponseWriter, r *http.Request) {
 	}
 	log.Info("request received", "request", req)
 
+	link, err := h.newLink(r.Context(), req, h.now())
+	if errors.Is(err, errResolveURL) {
+		log.Error(errResolveURL.Error(), "error", err)
+		h.renderJSON(w, http.StatusInternalServerError, response.Error(errResolveURL.Error()))
+		return
+	}
+	if err != nil {
+		log.Error("invalid request", "error", err)
+		h.renderJSON(w, http.StatusBadRequest, response.Error(err.Error()))
+		return
+	}
+
+	link, created, err := h.saveLink(r.Context(), link)
+	if err != nil {
+		msg := "failed to save url"
+		log.Error(msg, "error", err)
+
+		// Taken generated aliases are not the client's fault
+		if errors.Is(err, storage.ErrAliasExists) && req.Alias != "" {
+			h.renderJSON(w, http.StatusConflict, response.Error(storage.ErrAliasExists.Error()))
+			return
+		}
+
+		h.renderJSON(w, http.StatusInternalServerError, response.Error(msg))
+		return
+	}
+
+	log.Info("url saved", "id", link.ID, "alias", link.Alias, "created", created)
+
+	resp := CreateURLResponse{
+		Response: response.Ok(),
+		Alias:    link.Alias,
+		Reused:   !created,
+	}
+	if !link.ExpiresAt.IsZero() {
+		resp.ExpiresAt = &link.ExpiresAt
+	}
+
+	h.renderJSON(w, http.StatusOK, resp)
+}
+
+// saveLink saves link and returns it with its id. An empty alias is generated,
+// taken generated aliases are retried by the storage up to storage.MaxAliasAttempts times.
+// created is false if an existing link with the same normalized url was returned instead.
+func (h *Handler) saveLink(ctx context.Context, link storage.Link) (storage.Link, bool, error) {
+	if link.Alias == "" {
+		return h.storage.SaveGeneratedURL(ctx, link, h.generateAlias)
+	}
+
+	id, err := h.storage.SaveURL(ctx, link)
+	if err != nil {
+		return storage.Link{}, false, err
+	}
+	link.ID = id
+
+	return link, true, nil
+}
+
+// errInvalidURL is returned for urls which pass validation but cannot be normalized.
+var errInvalidURL = errors.New("'URL' is not a valid url")
+
+// newLink validates req and builds the link to save. The url is normalized and resolved,
+// the alias is left empty if it has to be generated. Errors are meant to be shown to the client,
+// except errResolveURL.
+func (h *Handler) newLink(ctx context.Context, req CreateURLRequest, now time.Time) (storage.Link, error) {
+	req.Alias = h.normalizeAlias(req.Alias)
+
+	if err := h.validator.Struct(req); err != nil {
+		var validationErr validator.ValidationErrors
+		if errors.As(err, &validationErr) {
+			return storage.Link{}, errors.New(response.ValidationError(validationErr).Error)
+		}
+		return storage.Link{}, err
+	}
+
+	validFrom, expiresAt, err := req.window(now)
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	url, err := h.urls.Normalize(req.URL)
+	if err != nil {
+		return storage.Link{}, errInvalidURL
+	}
+	if url, err = h.resolveDestination(ctx, url, req.Alias); err != nil {
+		return storage.Link{}, err
+	}
+	if err = h.checkDestination(url); err != nil {
+		return storage.Link{}, err
+	}
+
+	link := storage.Link{
+		Alias:        req.Alias,
+		URL:          url,
+		ValidFrom:    validFrom,
+		ExpiresAt:    expiresAt,
+		CreatedBy:    middleware.GetUser(ctx),
+		RedirectType: req.RedirectType,
+		ForwardQuery: req.ForwardQuery,
+		Prefix:       req.Prefix,
+		UTM:          req.UTM.storage(),
+		MaxClicks:    req.MaxClicks,
+	}
+
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
+			return storage.Link{}, errors.New("'Password' must be at most 72 bytes long")
+		}
+		if err != nil {
+			return storage.Link{}, err
+		}
+		link.PasswordHash = string(hash)
+	}
+
+	if h.dedup && reusable(link) {
+		link.NormalizedURL = link.URL
+	}
+
+	return link, nil
+}
+
+// reusable reports whether link is fully described by its destination, so that an existing
+// link to the same destination can be returned instead. Links with an alias or any other
+// setting are wanted as they are.
+func reusable(link storage.Link) bool {
+	return link.Alias == "" && link.ExpiresAt.IsZero() && link.RedirectType == 0 &&
+		!link.ForwardQuery && !link.Prefix && link.UTM == storage.UTM{} && link.PasswordHash == "" &&
+		link.MaxClicks == 0 && link.ValidFrom.IsZero()
+}
+
+// window resolves ValidFrom and ExpiresAt, ValidUntil or TTL into the absolute times the link
+// redirects between. TTL counts from ValidFrom if that is later than now.
+// Zero times mean the link is valid from now on or never expires.
+func (req CreateURLRequest) window(now time.Time) (validFrom, expiresAt time.Time, err error) {
+	start := now
+	if req.ValidFrom != nil {
+		validFrom = req.ValidFrom.UTC()
+		if validFrom.After(now) {
+			start = validFrom
+		}
+	}
+
+	end, field := req.ExpiresAt, "ExpiresAt"
+	if req.ValidUntil != nil {
+		end, field = req.ValidUntil, "ValidUntil"
+	}
+
+	switch {
+	case end != nil:
+		if !end.After(now) {
+			return time.Time{}, time.Time{}, fmt.Errorf("'%s' must be in the future", field)
+		}
+		if !end.After(start) {
+			return time.Time{}, time.Time{}, fmt.Errorf("'%s' must be after 'ValidFrom'", field)
+		}
+		expiresAt = end.UTC()
+	case req.TTL != "":
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return time.Time{}, time.Time{}, errors.New("'TTL' must be a positive duration")
+		}
+		expiresAt = start.Add(ttl).UTC()
+	}
+
+	return validFrom, expiresAt, nil
+}
+
+// redirect follows a short link. Protected links get a password form instead, see unlock.
+func (h *Handler) redirect(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.redirect"
+
+	log := slog.With(
+		"op", op,
+		string(middleware.RequestIDKey), middleware.GetRequestID(r.Context()),
+	)
+
+	alias, link, ok := h.lookupLink(w, r, log)
+	if !ok {
+		return
+	}
+
+	if link.PasswordHash != "" {
+		log.Info("password required", "alias", alias)
+		renderPasswordForm(w, http.StatusOK, "")
+		return
+	}
+
+	h.follow(w, r, log, alias, link, h.redirectStatus(link))
+}
+
+// lookupLink returns the link a redirect request is for.
+// Otherwise it renders the error and returns false.
+func (h *Handler) lookupLink(w http.ResponseWriter, r *http.Request, log *slog.Logger) (string, storage.Link, bool) {
+	alias := h.normalizeAlias(r.PathValue("alias"))
+
+	if alias == "" {
+		log.Info("alias is empty")
+		h.renderJSON(w, http.StatusBadRequest, response.Error("alias is empty"))
+		return "", storage.Link{}, false
+	}
+
+	link, err := h.storage.GetURL(r.Context(), alias)
+	if err != nil {
+		msg := "failed to get url"
+		log.Error(msg, "error", err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.renderJSON(w, http.StatusNotFound, response.Error(storage.ErrNotFound.Error()))
+			return "", storage.Link{}, false
+		}
+
+		if errors.Is(err, storage.ErrExpired) {
+			h.renderJSON(w, http.StatusGone, response.Error(storage.ErrExpired.Error()))
+			return "", storage.Link{}, false
+		}
+
+		if errors.Is(err, storage.ErrExhausted) {
+			h.renderJSON(w, http.StatusGone, response.Error(storage.ErrExhausted.Error()))
+			return "", storage.Link{}, false
+		}
+
+		h.renderJSON(w, http.StatusInternalServerError, response.Error(msg))
+		return "", storage.Link{}, false
+	}
+
+	log.Info("url found", "url", link.URL)
+
+	// The storage checks expiry too, but by its own clock
+	now := h.now()
+	if link.Expired(now) {
+		log.Info("url expired", "expires_at", link.ExpiresAt)
+		h.renderJSON(w, http.StatusGone, response.Error(storage.ErrExpired.Error()))
+		return "", storage.Link{}, false
+	}
+	if link.Pending(now) {
+		log.Info("url not valid yet", "valid_from", link.ValidFrom)
+		h.renderNotYetAvailable(w)
+		return "", storage.Link{}, false
+	}
+
+	return alias, link, true
+}
+
+// follow redirects the request to the destination of link with the given status and records the click.
+func (h *Handler) follow(w http.ResponseWriter, r *http.Request, log *slog.Logger, alias string, link storage.Link, status int) {
+	// Prefix links only append to the path, so their destination keeps the checked host
+	if err := h.checkDestination(link.URL); err != nil {
+		log.Warn("destination not allowed", "url", link.URL, "error", err)
+		h.renderJSON(w, http.StatusForbidden, response.Error("destination is not allowed"))
+		return
+	}
+
+	target, err := destination(link, restPath(r), r.URL.Query())
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Info("path suffix on a link without prefix", "path", r.URL.Path)
+			h.renderJSON(w, http.StatusNotFound, response.Error(storage.ErrNotFound.Error()))
+			return
+		}
+
+		msg := "failed to build destination"
+		log.Error(msg, "error", err)
+		h.renderJSON(w, http.StatusInternalServerError, response.Error(msg))
+		return
+	}
+
+	// HEAD requests of link unfurlers and probes are no clicks. Limited links do not
+	// disclose their destination to them, that would bypass the limit.
+	if r.Method == http.MethodHead {
+		if link.MaxClicks > 0 {
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		setCacheControl(w, status, link, h.now())
+		http.Redirect(w, r, target, status)
+		return
+	}
+
+	// Taking the click atomically keeps concurrent redirects within the limit
+	if link.MaxClicks > 0 {
+		if err = h.storage.ConsumeClick(r.Context(), alias); err != nil {
+			log.Info("failed to consume click", "error", err)
+
+			switch {
+			case errors.Is(err, storage.ErrExhausted):
+				h.renderJSON(w, http.StatusGone, response.Error(storage.ErrExhausted.Error()))
+			case errors.Is(err, storage.ErrNotFound):
+				h.renderJSON(w, http.StatusNotFound, response.Error(storage.ErrNotFound.Error()))
+			default:
+				h.renderJSON(w, http.StatusInternalServerError, response.Error("failed to get url"))
+			}
+			return
+		}
+	}
+
+	now := h.now()
+
+	h.clicks.Record(storage.Click{
+		Alias:     alias,
+		At:        now.UTC(),
+		Referrer:  r.Referer(),
+		UserAgent: r.UserAgent(),
+		RequestID: middleware.GetRequestID(r.Context()),
+	})
+
+	setCacheControl(w, status, link, now)
+	http.Redirect(w, r, target, status)
+}
+
+func (h *Handler) updateURL(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.updateURL"
+	log := slog.With(
+		"op", op,
+		string(middleware.RequestIDKey), middleware.GetRequestID(r.Context()),
+	)
+
+	alias := h.pathAlias(r)
+
+	var req UpdateURLRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Error("failed to decode request body", "error", err)
+		h.renderJSON(w, http.StatusBadRequest, response.Error(err.Error()))
+		return
+	}
+	log.Info("request received", "request", req)
+
 	if err := h.validator.Struct(req); err != nil {
 		msg := "validation error"
 		log.Error(msg, "error", err)
@@ -53,18 +420,34 @@ func (h *Handler) createURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	alias := req.Alias
-	if alias == "" {
-		alias = random.Alias(h.aliasLength)
+	url, err := h.urls.Normalize(req.URL)
+	if err != nil {
+		log.Info("invalid url", "error", err)
+		h.renderJSON(w, http.StatusBadRequest, response.Error(errInvalidURL.Error()))
+		return
+	}
+	url, err = h.resolveDestination(r.Context(), url, alias)
+	if errors.Is(err, errResolveURL) {
+		log.Error(errResolveURL.Error(), "error", err)
+		h.renderJSON(w, http.StatusInternalServerError, response.Error(errResolveURL.Error()))
+		return
+	}
+	if err == nil {
+		err = h.checkDestination(url)
+	}
+	if err != nil {
+		log.Info("destination not allowed", "url", url, "error", err)
+		h.renderJSON(w, http.StatusBadRequest, response.Error(err.Error()))
+		return
 	}
 
-	id, err := h.storage.SaveURL(req.URL, alias)
+	err = h.storage.UpdateURL(r.Context(), alias, url)
 	if err != nil {
-		msg := "failed to save url"
+		msg := "failed to update url"
 		log.Error(msg, "error", err)
 
-		if errors.Is(err, storage.ErrAliasExists) {
-			h.renderJSON(w, http.StatusConflict, response.Error(storage.ErrAliasExists.Error()))
+		if errors.Is(err, storage.ErrNotFound) {
+			h.renderJSON(w, http.StatusNotFound, response.Error(storage.ErrNotFound.Error()))
 			return
 		}
 
@@ -72,23 +455,20 @@ func (h *Handler) createURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Info("url saved", "id", id, "alias", alias)
+	log.Info("url updated", "alias", alias, "url", url)
 
-	h.renderJSON(w, http.StatusOK, CreateURLResponse{
-		Response: response.Ok(),
-		Alias:    alias,
-	})
+	h.renderJSON(w, http.StatusOK, response.Ok())
 }
 
-func (h *Handler) redirect(w http.ResponseWriter, r *http.Request) {
-	const op = "handler.redirect"
+func (h *Handler) deleteURL(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.deleteURL"
 
 	log := slog.With(
 		"op", op,
 		string(middleware.RequestIDKey), middleware.GetRequestID(r.Context()),
 	)
 
-	alias := r.URL.Path[1:]
+	alias := h.pathAlias(r)
 
 	if alias == "" {
 		log.Info("alias is empty")
@@ -96,9 +476,9 @@ func (h *Handler) redirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	url, err := h.storage.GetURL(alias)
+	err := h.storage.DeleteURL(r.Context(), alias)
 	if err != nil {
-		msg := "failed to get url"
+		msg := "failed to delete url"
 		log.Error(msg, "error", err)
 
 		if errors.Is(err, storage.ErrNotFound) {
@@ -110,7 +490,7 @@ func (h *Handler) redirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Info("url found", "url", url)
+	log.Info("url deleted", "alias", alias)
 
-	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
+	h.renderJSON(w, http.StatusOK, response.Ok())
 }
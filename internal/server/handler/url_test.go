@@ -2,28 +2,38 @@ package handler_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/zulerne/url-shortener/internal/lib/logger"
 	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/server/response"
 	"github.com/zulerne/url-shortener/internal/storage"
 )
 
 type reqBody struct {
-	URL   string `json:"url"`
-	Alias string `json:"alias,omitempty"`
+	URL       string     `json:"url"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
+	Prefix    bool       `json:"prefix,omitempty"`
 }
 
 func TestCreateURLHandler(t *testing.T) {
 	slog.SetDefault(logger.NewDiscardLogger())
 
+	expiresAt := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	expiredAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
 	cases := []struct {
 		name      string
 		input     reqBody
@@ -34,13 +44,13 @@ func TestCreateURLHandler(t *testing.T) {
 		{
 			name: "Success",
 			input: reqBody{
-				URL:   "https://google.com",
+				URL:   "https://google.com/",
 				Alias: "test_alias",
 			},
 			code: http.StatusOK,
 			mockSetup: func(s *MockStorage) {
 				s.EXPECT().
-					SaveURL("https://google.com", "test_alias").
+					SaveURL(mock.Anything, storage.Link{Alias: "test_alias", URL: "https://google.com/"}).
 					Return(1, nil).
 					Once()
 			},
@@ -48,13 +58,13 @@ func TestCreateURLHandler(t *testing.T) {
 		{
 			name: "Empty alias (Auto-generated)",
 			input: reqBody{
-				URL: "https://google.com",
+				URL: "https://google.com/",
 			},
 			code: http.StatusOK,
 			mockSetup: func(s *MockStorage) {
 				s.EXPECT().
-					SaveURL("https://google.com", mock.AnythingOfType("string")).
-					Return(1, nil).
+					SaveGeneratedURL(mock.Anything, storage.Link{URL: "https://google.com/"}, mock.Anything).
+					Return(storage.Link{ID: 1, Alias: "abc123", URL: "https://google.com/"}, true, nil).
 					Once()
 			},
 		},
@@ -78,17 +88,86 @@ func TestCreateURLHandler(t *testing.T) {
 			respError: "'URL' is not a valid url",
 			mockSetup: nil,
 		},
+		{
+			name: "With TTL",
+			input: reqBody{
+				URL:   "https://google.com/",
+				Alias: "ttl_alias",
+				TTL:   "1h",
+			},
+			code: http.StatusOK,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveURL(mock.Anything, mock.MatchedBy(func(link storage.Link) bool {
+						return link.Alias == "ttl_alias" &&
+							link.ExpiresAt.After(time.Now().Add(59*time.Minute)) &&
+							link.ExpiresAt.Before(time.Now().Add(61*time.Minute))
+					})).
+					Return(1, nil).
+					Once()
+			},
+		},
+		{
+			name: "With ExpiresAt",
+			input: reqBody{
+				URL:       "https://google.com/",
+				Alias:     "expiring_alias",
+				ExpiresAt: &expiresAt,
+			},
+			code: http.StatusOK,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveURL(mock.Anything, storage.Link{
+						Alias:     "expiring_alias",
+						URL:       "https://google.com/",
+						ExpiresAt: expiresAt,
+					}).
+					Return(1, nil).
+					Once()
+			},
+		},
+		{
+			name: "ExpiresAt In The Past",
+			input: reqBody{
+				URL:       "https://google.com/",
+				Alias:     "some_alias",
+				ExpiresAt: &expiredAt,
+			},
+			code:      http.StatusBadRequest,
+			respError: "'ExpiresAt' must be in the future",
+		},
+		{
+			name: "Invalid TTL",
+			input: reqBody{
+				URL:   "https://google.com/",
+				Alias: "some_alias",
+				TTL:   "tomorrow",
+			},
+			code:      http.StatusBadRequest,
+			respError: "'TTL' must be a positive duration",
+		},
+		{
+			name: "Both ExpiresAt And TTL",
+			input: reqBody{
+				URL:       "https://google.com/",
+				Alias:     "some_alias",
+				ExpiresAt: &expiresAt,
+				TTL:       "1h",
+			},
+			code:      http.StatusBadRequest,
+			respError: "'ExpiresAt' cannot be used together with 'TTL'",
+		},
 		{
 			name: "SaveURL Internal Error",
 			input: reqBody{
-				URL:   "https://google.com",
+				URL:   "https://google.com/",
 				Alias: "fail",
 			},
 			code:      http.StatusInternalServerError,
 			respError: "failed to save url",
 			mockSetup: func(s *MockStorage) {
 				s.EXPECT().
-					SaveURL("https://google.com", "fail").
+					SaveURL(mock.Anything, storage.Link{Alias: "fail", URL: "https://google.com/"}).
 					Return(0, errors.New("unexpected db error")).
 					Once()
 			},
@@ -96,18 +175,32 @@ func TestCreateURLHandler(t *testing.T) {
 		{
 			name: "Alias Already Exists",
 			input: reqBody{
-				URL:   "https://google.com",
+				URL:   "https://google.com/",
 				Alias: "exists",
 			},
 			code:      http.StatusConflict,
 			respError: storage.ErrAliasExists.Error(),
 			mockSetup: func(s *MockStorage) {
 				s.EXPECT().
-					SaveURL("https://google.com", "exists").
+					SaveURL(mock.Anything, storage.Link{Alias: "exists", URL: "https://google.com/"}).
 					Return(0, storage.ErrAliasExists).
 					Once()
 			},
 		},
+		{
+			name: "Generated Alias Collisions (Attempts Exhausted)",
+			input: reqBody{
+				URL: "https://google.com/",
+			},
+			code:      http.StatusInternalServerError,
+			respError: "failed to save url",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveGeneratedURL(mock.Anything, storage.Link{URL: "https://google.com/"}, mock.Anything).
+					Return(storage.Link{}, false, storage.ErrAliasExists).
+					Once()
+			},
+		},
 	}
 
 	for _, tc := range cases {
@@ -141,6 +234,162 @@ func TestCreateURLHandler(t *testing.T) {
 	}
 }
 
+func TestCreateURLHandlerAliasGenerator(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	generatorMock := NewMockAliasGenerator(t)
+	generatorMock.EXPECT().
+		Generate(int64(42), 0).
+		Return("generated").
+		Once()
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().
+		SaveGeneratedURL(mock.Anything, storage.Link{URL: "https://google.com/"}, mock.Anything).
+		RunAndReturn(func(_ context.Context, link storage.Link, aliasOf storage.AliasFunc) (storage.Link, bool, error) {
+			link.ID = 42
+			link.Alias = aliasOf(link.ID, 0)
+			return link, true, nil
+		}).
+		Once()
+
+	h := handler.NewHandler(storageMock, 6, "", "", handler.WithAliasGenerator(generatorMock))
+
+	body, _ := json.Marshal(reqBody{URL: "https://google.com/"})
+	req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("", "")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp handler.CreateURLResponse
+	err := json.Unmarshal(w.Body.Bytes(), &resp)
+	require.NoError(t, err)
+	require.Equal(t, "generated", resp.Alias)
+}
+
+func TestCreateURLHandlerDedup(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	cases := []struct {
+		name      string
+		input     reqBody
+		reused    bool
+		mockSetup func(s *MockStorage)
+	}{
+		{
+			name:   "Reused",
+			input:  reqBody{URL: "HTTPS://Google.com:443"},
+			reused: true,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveGeneratedURL(mock.Anything, storage.Link{
+						URL:           "https://google.com/",
+						NormalizedURL: "https://google.com/",
+					}, mock.Anything).
+					Return(storage.Link{ID: 1, Alias: "abc123", URL: "https://google.com/"}, false, nil).
+					Once()
+			},
+		},
+		{
+			name:  "Created",
+			input: reqBody{URL: "https://google.com/"},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveGeneratedURL(mock.Anything, storage.Link{
+						URL:           "https://google.com/",
+						NormalizedURL: "https://google.com/",
+					}, mock.Anything).
+					Return(storage.Link{ID: 2, Alias: "abc123", URL: "https://google.com/"}, true, nil).
+					Once()
+			},
+		},
+		{
+			name:  "Custom alias is not deduplicated",
+			input: reqBody{URL: "https://google.com/", Alias: "google"},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveURL(mock.Anything, storage.Link{Alias: "google", URL: "https://google.com/"}).
+					Return(3, nil).
+					Once()
+			},
+		},
+		{
+			name:  "Expiring link is not deduplicated",
+			input: reqBody{URL: "https://google.com/", ExpiresAt: &expiresAt},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveGeneratedURL(mock.Anything, storage.Link{URL: "https://google.com/", ExpiresAt: expiresAt}, mock.Anything).
+					Return(storage.Link{ID: 4, Alias: "abc123", URL: "https://google.com/"}, true, nil).
+					Once()
+			},
+		},
+		{
+			name:  "Prefix link is not deduplicated",
+			input: reqBody{URL: "https://google.com/", Prefix: true},
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					SaveGeneratedURL(mock.Anything, storage.Link{URL: "https://google.com/", Prefix: true}, mock.Anything).
+					Return(storage.Link{ID: 5, Alias: "abc123", URL: "https://google.com/"}, true, nil).
+					Once()
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			tc.mockSetup(storageMock)
+
+			h := handler.NewHandler(storageMock, 6, "", "", handler.WithDedup(true))
+
+			body, _ := json.Marshal(tc.input)
+			req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+			req.SetBasicAuth("", "")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var resp handler.CreateURLResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tc.reused, resp.Reused)
+		})
+	}
+}
+
+func TestCreateURLHandlerCanonicalURL(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().
+		SaveURL(mock.Anything, storage.Link{Alias: "canonical", URL: "https://example.com/b?a=1&z=2"}).
+		Return(1, nil).
+		Once()
+
+	h := handler.NewHandler(storageMock, 6, "", "", handler.WithStripParams([]string{"utm_*", "fbclid"}))
+
+	body, _ := json.Marshal(reqBody{
+		URL:   "https://Example.com:443/a/../b?z=2&utm_source=x&fbclid=y&a=1",
+		Alias: "canonical",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
+	req.SetBasicAuth("", "")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
 func TestCreateURLHandlerAuth(t *testing.T) {
 	slog.SetDefault(logger.NewDiscardLogger())
 
@@ -161,7 +410,7 @@ func TestCreateURLHandlerAuth(t *testing.T) {
 			pass: pass,
 			mockSetup: func(s *MockStorage) {
 				s.EXPECT().
-					SaveURL("https://google.com", "test_alias").
+					SaveURL(mock.Anything, storage.Link{Alias: "test_alias", URL: "https://google.com/", CreatedBy: user}).
 					Return(1, nil).
 					Once()
 			},
@@ -192,7 +441,7 @@ func TestCreateURLHandlerAuth(t *testing.T) {
 			h := handler.NewHandler(storageMock, 6, user, pass)
 
 			body, _ := json.Marshal(reqBody{
-				URL:   "https://google.com",
+				URL:   "https://google.com/",
 				Alias: "test_alias",
 			})
 			req := httptest.NewRequest(http.MethodPost, "/url", bytes.NewReader(body))
@@ -221,11 +470,11 @@ func TestRedirectHandler(t *testing.T) {
 			name:        "Success",
 			code:        http.StatusTemporaryRedirect,
 			alias:       "test_alias",
-			redirectURL: "https://google.com",
+			redirectURL: "https://google.com/",
 			mockSetup: func(s *MockStorage) {
 				s.EXPECT().
-					GetURL("test_alias").
-					Return("https://google.com", nil).
+					GetURL(mock.Anything, "test_alias").
+					Return(storage.Link{URL: "https://google.com/"}, nil).
 					Once()
 			},
 		},
@@ -235,8 +484,19 @@ func TestRedirectHandler(t *testing.T) {
 			alias: "not_found",
 			mockSetup: func(s *MockStorage) {
 				s.EXPECT().
-					GetURL("not_found").
-					Return("", storage.ErrNotFound).
+					GetURL(mock.Anything, "not_found").
+					Return(storage.Link{}, storage.ErrNotFound).
+					Once()
+			},
+		},
+		{
+			name:  "Expired",
+			code:  http.StatusGone,
+			alias: "expired",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					GetURL(mock.Anything, "expired").
+					Return(storage.Link{}, storage.ErrExpired).
 					Once()
 			},
 		},
@@ -268,3 +528,221 @@ func TestRedirectHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestUpdateURLHandler(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	user := "user"
+	pass := "pass"
+
+	cases := []struct {
+		name      string
+		code      int
+		alias     string
+		body      string
+		pass      string
+		respError string
+		mockSetup func(s *MockStorage)
+	}{
+		{
+			name:  "Success",
+			code:  http.StatusOK,
+			alias: "test_alias",
+			body:  `{"url": "https://example.com/"}`,
+			pass:  pass,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					UpdateURL(mock.Anything, "test_alias", "https://example.com/").
+					Return(nil).
+					Once()
+			},
+		},
+		{
+			name:  "Canonical URL",
+			code:  http.StatusOK,
+			alias: "test_alias",
+			body:  `{"url": "HTTP://Example.com:80/./a?b=2&a=1"}`,
+			pass:  pass,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					UpdateURL(mock.Anything, "test_alias", "http://example.com/a?a=1&b=2").
+					Return(nil).
+					Once()
+			},
+		},
+		{
+			name:      "NotFound",
+			code:      http.StatusNotFound,
+			alias:     "not_found",
+			body:      `{"url": "https://example.com/"}`,
+			pass:      pass,
+			respError: storage.ErrNotFound.Error(),
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					UpdateURL(mock.Anything, "not_found", "https://example.com/").
+					Return(storage.ErrNotFound).
+					Once()
+			},
+		},
+		{
+			name:      "Empty URL",
+			code:      http.StatusBadRequest,
+			alias:     "test_alias",
+			body:      `{}`,
+			pass:      pass,
+			respError: "'URL' is required",
+		},
+		{
+			name:      "Invalid URL",
+			code:      http.StatusBadRequest,
+			alias:     "test_alias",
+			body:      `{"url": "not-a-valid-url"}`,
+			pass:      pass,
+			respError: "'URL' is not a valid url",
+		},
+		{
+			name:      "UpdateURL Internal Error",
+			code:      http.StatusInternalServerError,
+			alias:     "fail",
+			body:      `{"url": "https://example.com/"}`,
+			pass:      pass,
+			respError: "failed to update url",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					UpdateURL(mock.Anything, "fail", "https://example.com/").
+					Return(errors.New("unexpected db error")).
+					Once()
+			},
+		},
+		{
+			name:  "Unauthorized",
+			code:  http.StatusUnauthorized,
+			alias: "test_alias",
+			body:  `{"url": "https://example.com/"}`,
+			pass:  "wrong_pass",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.mockSetup != nil {
+				tc.mockSetup(storageMock)
+			}
+
+			h := handler.NewHandler(storageMock, 6, user, pass)
+
+			req := httptest.NewRequest(http.MethodPatch, "/url/"+tc.alias, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.SetBasicAuth(user, tc.pass)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+
+			if tc.respError != "" {
+				var resp response.Response
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				require.NoError(t, err)
+				require.Equal(t, tc.respError, resp.Error)
+			}
+		})
+	}
+}
+
+func TestDeleteURLHandler(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	user := "user"
+	pass := "pass"
+
+	cases := []struct {
+		name      string
+		code      int
+		alias     string
+		user      string
+		pass      string
+		respError string
+		mockSetup func(s *MockStorage)
+	}{
+		{
+			name:  "Success",
+			code:  http.StatusOK,
+			alias: "test_alias",
+			user:  user,
+			pass:  pass,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					DeleteURL(mock.Anything, "test_alias").
+					Return(nil).
+					Once()
+			},
+		},
+		{
+			name:      "NotFound",
+			code:      http.StatusNotFound,
+			alias:     "not_found",
+			user:      user,
+			pass:      pass,
+			respError: storage.ErrNotFound.Error(),
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					DeleteURL(mock.Anything, "not_found").
+					Return(storage.ErrNotFound).
+					Once()
+			},
+		},
+		{
+			name:      "DeleteURL Internal Error",
+			code:      http.StatusInternalServerError,
+			alias:     "fail",
+			user:      user,
+			pass:      pass,
+			respError: "failed to delete url",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					DeleteURL(mock.Anything, "fail").
+					Return(errors.New("unexpected db error")).
+					Once()
+			},
+		},
+		{
+			name:  "Unauthorized",
+			code:  http.StatusUnauthorized,
+			alias: "test_alias",
+			user:  user,
+			pass:  "wrong_pass",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.mockSetup != nil {
+				tc.mockSetup(storageMock)
+			}
+
+			h := handler.NewHandler(storageMock, 6, user, pass)
+
+			req := httptest.NewRequest(http.MethodDelete, "/url/"+tc.alias, nil)
+			req.SetBasicAuth(tc.user, tc.pass)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+
+			if tc.respError != "" {
+				var resp response.Response
+				err := json.Unmarshal(w.Body.Bytes(), &resp)
+				require.NoError(t, err)
+				require.Equal(t, tc.respError, resp.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/zulerne/url-shortener/internal/server/middleware"
+	"github.com/zulerne/url-shortener/internal/server/response"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+// UTM are the utm_* parameters added to the destination of a link on redirect.
+// They replace parameters of the same name in the destination, empty ones are not added.
+type UTM struct {
+	Source   string `json:"source,omitempty" validate:"max=200"`
+	Medium   string `json:"medium,omitempty" validate:"max=200"`
+	Campaign string `json:"campaign,omitempty" validate:"max=200"`
+	Term     string `json:"term,omitempty" validate:"max=200"`
+	Content  string `json:"content,omitempty" validate:"max=200"`
+}
+
+func newUTM(utm storage.UTM) UTM {
+	return UTM(utm)
+}
+
+func (u UTM) storage() storage.UTM {
+	return storage.UTM(u)
+}
+
+// utmParams returns the query parameters of the set tags of utm.
+func utmParams(utm storage.UTM) url.Values {
+	params := make(url.Values)
+	for key, value := range map[string]string{
+		"utm_source":   utm.Source,
+		"utm_medium":   utm.Medium,
+		"utm_campaign": utm.Campaign,
+		"utm_term":     utm.Term,
+		"utm_content":  utm.Content,
+	} {
+		if value != "" {
+			params.Set(key, value)
+		}
+	}
+	return params
+}
+
+// updateUTM replaces the UTM tags of a link, an empty object removes them.
+func (h *Handler) updateUTM(w http.ResponseWriter, r *http.Request) {
+	const op = "handler.updateUTM"
+	log := slog.With(
+		"op", op,
+		string(middleware.RequestIDKey), middleware.GetRequestID(r.Context()),
+	)
+
+	alias := h.pathAlias(r)
+
+	var req UTM
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		log.Error("failed to decode request body", "error", err)
+		h.renderJSON(w, http.StatusBadRequest, response.Error(err.Error()))
+		return
+	}
+	log.Info("request received", "request", req)
+
+	if err := h.validator.Struct(req); err != nil {
+		msg := "validation error"
+		log.Error(msg, "error", err)
+
+		var validationErr validator.ValidationErrors
+		if !errors.As(err, &validationErr) {
+			h.renderJSON(w, http.StatusInternalServerError, response.Error(msg))
+			return
+		}
+
+		h.renderJSON(w, http.StatusBadRequest, response.ValidationError(validationErr))
+		return
+	}
+
+	err = h.storage.UpdateUTM(r.Context(), alias, req.storage())
+	if err != nil {
+		msg := "failed to update utm"
+		log.Error(msg, "error", err)
+
+		if errors.Is(err, storage.ErrNotFound) {
+			h.renderJSON(w, http.StatusNotFound, response.Error(storage.ErrNotFound.Error()))
+			return
+		}
+
+		h.renderJSON(w, http.StatusInternalServerError, response.Error(msg))
+		return
+	}
+
+	log.Info("utm updated", "alias", alias)
+
+	h.renderJSON(w, http.StatusOK, response.Ok())
+}
@@ -0,0 +1,187 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/lib/logger"
+	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+func TestRedirectUTM(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	cases := []struct {
+		name     string
+		link     storage.Link
+		path     string
+		location string
+	}{
+		{
+			name:     "Tags Added",
+			link:     storage.Link{URL: "https://example.com/shop?id=1", UTM: storage.UTM{Source: "newsletter", Medium: "email", Campaign: "spring sale"}},
+			path:     "/abc",
+			location: "https://example.com/shop?id=1&utm_campaign=spring+sale&utm_medium=email&utm_source=newsletter",
+		},
+		{
+			name:     "Tags Replace Destination Parameters",
+			link:     storage.Link{URL: "https://example.com/?utm_source=old&utm_term=shoes", UTM: storage.UTM{Source: "new"}},
+			path:     "/abc",
+			location: "https://example.com/?utm_source=new&utm_term=shoes",
+		},
+		{
+			name:     "Tags Win Over Forwarded Query",
+			link:     storage.Link{URL: "https://example.com/", ForwardQuery: true, UTM: storage.UTM{Content: "banner"}},
+			path:     "/abc?utm_content=evil&ref=x",
+			location: "https://example.com/?ref=x&utm_content=banner",
+		},
+		{
+			name:     "Tags On Prefix Link",
+			link:     storage.Link{URL: "https://example.com/docs", Prefix: true, UTM: storage.UTM{Term: "go"}},
+			path:     "/abc/guide",
+			location: "https://example.com/docs/guide?utm_term=go",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			storageMock.EXPECT().GetURL(mock.Anything, "abc").Return(tc.link, nil).Once()
+
+			h := handler.NewHandler(storageMock, 6, "", "")
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusTemporaryRedirect, w.Code)
+			require.Equal(t, tc.location, w.Header().Get("Location"))
+		})
+	}
+}
+
+func TestCreateURLHandlerUTM(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	storageMock := NewMockStorage(t)
+	storageMock.EXPECT().
+		SaveGeneratedURL(mock.Anything, storage.Link{
+			URL: "https://example.com/",
+			UTM: storage.UTM{Source: "newsletter", Campaign: "spring"},
+		}, mock.Anything).
+		Return(storage.Link{ID: 1, Alias: "abc123"}, true, nil).
+		Once()
+
+	// Tagged links are not deduplicated
+	h := handler.NewHandler(storageMock, 6, "", "", handler.WithDedup(true))
+
+	body := `{"url": "https://example.com", "utm": {"source": "newsletter", "campaign": "spring"}}`
+	req := httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(body))
+	req.SetBasicAuth("", "")
+	w := httptest.NewRecorder()
+
+	h.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUpdateUTMHandler(t *testing.T) {
+	slog.SetDefault(logger.NewDiscardLogger())
+
+	cases := []struct {
+		name      string
+		code      int
+		body      string
+		respError string
+		mockSetup func(s *MockStorage)
+	}{
+		{
+			name: "Success",
+			code: http.StatusOK,
+			body: `{"source": "blog", "medium": "post"}`,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					UpdateUTM(mock.Anything, "abc", storage.UTM{Source: "blog", Medium: "post"}).
+					Return(nil).
+					Once()
+			},
+		},
+		{
+			name: "Clear",
+			code: http.StatusOK,
+			body: `{}`,
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					UpdateUTM(mock.Anything, "abc", storage.UTM{}).
+					Return(nil).
+					Once()
+			},
+		},
+		{
+			name:      "NotFound",
+			code:      http.StatusNotFound,
+			body:      `{"source": "blog"}`,
+			respError: storage.ErrNotFound.Error(),
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					UpdateUTM(mock.Anything, "abc", storage.UTM{Source: "blog"}).
+					Return(storage.ErrNotFound).
+					Once()
+			},
+		},
+		{
+			name:      "Too Long",
+			code:      http.StatusBadRequest,
+			body:      `{"campaign": "` + strings.Repeat("a", 201) + `"}`,
+			respError: "'Campaign' must be at most 200 characters long",
+		},
+		{
+			name:      "Storage Error",
+			code:      http.StatusInternalServerError,
+			body:      `{"source": "blog"}`,
+			respError: "failed to update utm",
+			mockSetup: func(s *MockStorage) {
+				s.EXPECT().
+					UpdateUTM(mock.Anything, "abc", storage.UTM{Source: "blog"}).
+					Return(errors.New("unexpected db error")).
+					Once()
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			storageMock := NewMockStorage(t)
+			if tc.mockSetup != nil {
+				tc.mockSetup(storageMock)
+			}
+
+			h := handler.NewHandler(storageMock, 6, "user", "pass")
+
+			req := httptest.NewRequest(http.MethodPut, "/url/abc/utm", strings.NewReader(tc.body))
+			req.SetBasicAuth("user", "pass")
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, req)
+
+			require.Equal(t, tc.code, w.Code)
+
+			var resp handler.CreateURLResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			require.Equal(t, tc.respError, resp.Error)
+		})
+	}
+}
@@ -1,10 +1,16 @@
 package middleware
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 )
 
+// UserKey is the context key for the authenticated user
+const UserKey contextKey = "user"
+
+// BasicAuth rejects requests without valid credentials
+// and stores the authenticated user in the request context.
 func BasicAuth(userPassMap map[string]string) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -21,7 +27,17 @@ func BasicAuth(userPassMap map[string]string) Middleware {
 				return
 			}
 
-			next.ServeHTTP(w, r)
+			ctx := context.WithValue(r.Context(), UserKey, authUser)
+			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// GetUser extracts the authenticated user from context.
+// Returns empty string if not found.
+func GetUser(ctx context.Context) string {
+	if user, ok := ctx.Value(UserKey).(string); ok {
+		return user
+	}
+	return ""
+}
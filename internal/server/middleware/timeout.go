@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Timeout middleware cancels the request context after the given duration,
+// so storage calls made on behalf of a slow request are aborted as well.
+// The context is also canceled as soon as the client disconnects.
+func Timeout(timeout time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		slog.Debug("Timeout middleware enabled", "timeout", timeout)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -2,6 +2,7 @@ package response
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
@@ -34,6 +35,26 @@ func ValidationError(errs validator.ValidationErrors) Response {
 			msgs = append(msgs, fmt.Sprintf("'%s' is required", err.Field()))
 		case "url":
 			msgs = append(msgs, fmt.Sprintf("'%s' is not a valid url", err.Field()))
+		case "excluded_with":
+			msgs = append(msgs, fmt.Sprintf("'%s' cannot be used together with '%s'", err.Field(), err.Param()))
+		case "min":
+			if err.Kind() != reflect.String {
+				msgs = append(msgs, fmt.Sprintf("'%s' must be at least %s", err.Field(), err.Param()))
+				break
+			}
+			msgs = append(msgs, fmt.Sprintf("'%s' must be at least %s characters long", err.Field(), err.Param()))
+		case "max":
+			if err.Kind() != reflect.String {
+				msgs = append(msgs, fmt.Sprintf("'%s' must be at most %s", err.Field(), err.Param()))
+				break
+			}
+			msgs = append(msgs, fmt.Sprintf("'%s' must be at most %s characters long", err.Field(), err.Param()))
+		case "oneof":
+			msgs = append(msgs, fmt.Sprintf("'%s' must be one of %s", err.Field(), strings.ReplaceAll(err.Param(), " ", ", ")))
+		case "alias_charset":
+			msgs = append(msgs, fmt.Sprintf("'%s' may only contain the characters %q", err.Field(), err.Param()))
+		case "alias_reserved":
+			msgs = append(msgs, fmt.Sprintf("'%s' is reserved", err.Field()))
 		default:
 			msgs = append(msgs, fmt.Sprintf("'%s' is invalid", err.Field()))
 		}
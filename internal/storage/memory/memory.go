@@ -0,0 +1,410 @@
+package memory
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+// Storage keeps urls in process memory. Data is lost on restart,
+// so it is meant for tests and ephemeral deployments.
+type Storage struct {
+	mu     sync.RWMutex
+	lastID int64
+	links  map[string]storage.Link
+	// clicks are keyed by link id, so a re-created alias starts from zero
+	clicks map[int64][]storage.Click
+}
+
+func New() *Storage {
+	return &Storage{
+		links:  make(map[string]storage.Link),
+		clicks: make(map[int64][]storage.Click),
+	}
+}
+
+func (s *Storage) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
+	const op = "storage.memory.SaveURL"
+
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Custom aliases are not deduplicated
+	link.NormalizedURL = ""
+	id, err := s.save(link, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SaveGeneratedURL saves link under an alias generated by aliasOf and returns it with
+// its ID and Alias set. If another link has the same NormalizedURL, nothing is saved
+// and that link is returned with created set to false.
+func (s *Storage) SaveGeneratedURL(ctx context.Context, link storage.Link, aliasOf storage.AliasFunc) (storage.Link, bool, error) {
+	const op = "storage.memory.SaveGeneratedURL"
+
+	if err := ctx.Err(); err != nil {
+		return storage.Link{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, created, err := s.saveGenerated(link, time.Now().UTC(), aliasOf)
+	if err != nil {
+		return storage.Link{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, created, nil
+}
+
+// SaveURLs saves links atomically. Links without an alias get one from aliasOf
+// and are deduplicated like in SaveGeneratedURL. A taken alias fails only its own link.
+func (s *Storage) SaveURLs(ctx context.Context, links []storage.Link, aliasOf storage.AliasFunc) ([]storage.SaveResult, error) {
+	const op = "storage.memory.SaveURLs"
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UTC()
+	results := make([]storage.SaveResult, len(links))
+	for i, link := range links {
+		if link.Alias == "" {
+			saved, created, err := s.saveGenerated(link, now, aliasOf)
+			results[i] = storage.SaveResult{ID: saved.ID, Alias: saved.Alias, Reused: err == nil && !created, Err: err}
+			continue
+		}
+
+		link.NormalizedURL = ""
+		results[i].Alias = link.Alias
+		results[i].ID, results[i].Err = s.save(link, now)
+	}
+
+	return results, nil
+}
+
+// saveGenerated stores link under an alias generated from its future id. Taken aliases
+// skip the id like the sql backends do, so aliases derived from it change too.
+// A link with the same NormalizedURL is returned instead of storing a duplicate.
+// Callers must hold s.mu for writing.
+func (s *Storage) saveGenerated(link storage.Link, now time.Time, aliasOf storage.AliasFunc) (storage.Link, bool, error) {
+	if link.NormalizedURL != "" {
+		for _, existing := range s.links {
+			if existing.NormalizedURL == link.NormalizedURL {
+				return s.withClicks(existing), false, nil
+			}
+		}
+	}
+
+	for attempt := range storage.MaxAliasAttempts {
+		link.Alias = aliasOf(s.lastID+1, attempt)
+		if link.Alias != "" {
+			if id, err := s.save(link, now); err == nil {
+				link.ID = id
+				link.CreatedAt = now
+				return link, true, nil
+			}
+		}
+
+		s.lastID++
+	}
+
+	return storage.Link{}, false, storage.ErrAliasExists
+}
+
+// save stores link unless its alias is taken. Callers must hold s.mu for writing.
+func (s *Storage) save(link storage.Link, now time.Time) (int64, error) {
+	if _, exists := s.links[link.Alias]; exists {
+		return 0, storage.ErrAliasExists
+	}
+
+	s.lastID++
+	link.ID = s.lastID
+	link.CreatedAt = now
+	link.Clicks = 0
+	link.ClicksUsed = 0
+	s.links[link.Alias] = link
+
+	return link.ID, nil
+}
+
+// GetURL returns the link of alias for redirecting. It fails with storage.ErrExpired
+// for expired links and storage.ErrExhausted for links without clicks left. It does not count clicks.
+func (s *Storage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.memory.GetURL"
+
+	if err := ctx.Err(); err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, exists := s.links[alias]
+	if !exists {
+		return storage.Link{}, storage.ErrNotFound
+	}
+
+	if link.Expired(time.Now()) {
+		return storage.Link{}, storage.ErrExpired
+	}
+	if link.Exhausted() {
+		return storage.Link{}, storage.ErrExhausted
+	}
+
+	return link, nil
+}
+
+// GetLink returns the full record of an alias, including expired ones.
+func (s *Storage) GetLink(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.memory.GetLink"
+
+	if err := ctx.Err(); err != nil {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, exists := s.links[alias]
+	if !exists {
+		return storage.Link{}, storage.ErrNotFound
+	}
+
+	return s.withClicks(link), nil
+}
+
+// ConsumeClick uses up a click of a link with MaxClicks, so that it fails with storage.ErrExhausted
+// once none are left. Concurrent calls never use more clicks than allowed. Links without MaxClicks
+// are not counted.
+func (s *Storage) ConsumeClick(ctx context.Context, alias string) error {
+	const op = "storage.memory.ConsumeClick"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, exists := s.links[alias]
+	if !exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if link.MaxClicks == 0 {
+		return nil
+	}
+	if link.Exhausted() {
+		return fmt.Errorf("%s: %w", op, storage.ErrExhausted)
+	}
+
+	link.ClicksUsed++
+	s.links[alias] = link
+
+	return nil
+}
+
+// UpdateURL changes the destination of an existing alias.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, urlToSave string) error {
+	const op = "storage.memory.UpdateURL"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, exists := s.links[alias]
+	if !exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	link.URL = urlToSave
+	link.UpdatedAt = time.Now().UTC()
+	// The new destination is not deduplicated
+	link.NormalizedURL = ""
+	s.links[alias] = link
+
+	return nil
+}
+
+// UpdateUTM replaces the UTM tags of an existing alias.
+func (s *Storage) UpdateUTM(ctx context.Context, alias string, utm storage.UTM) error {
+	const op = "storage.memory.UpdateUTM"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, exists := s.links[alias]
+	if !exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	link.UTM = utm
+	link.UpdatedAt = time.Now().UTC()
+	// Tagged links are not deduplicated
+	link.NormalizedURL = ""
+	s.links[alias] = link
+
+	return nil
+}
+
+func (s *Storage) DeleteURL(ctx context.Context, alias string) error {
+	const op = "storage.memory.DeleteURL"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, exists := s.links[alias]
+	if !exists {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	delete(s.links, alias)
+	delete(s.clicks, link.ID)
+
+	return nil
+}
+
+// DeleteExpiredURLs removes urls that expired at or before now
+// and returns how many were removed.
+func (s *Storage) DeleteExpiredURLs(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.memory.DeleteExpiredURLs"
+
+	if err := ctx.Err(); err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for alias, link := range s.links {
+		if link.Expired(now) {
+			delete(s.links, alias)
+			delete(s.clicks, link.ID)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// SaveClicks stores a batch of clicks. Clicks of aliases that no longer exist are skipped.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const op = "storage.memory.SaveClicks"
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, click := range clicks {
+		link, exists := s.links[click.Alias]
+		if !exists {
+			continue
+		}
+		s.clicks[link.ID] = append(s.clicks[link.ID], click)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetStats(ctx context.Context, alias string) (storage.Stats, error) {
+	const op = "storage.memory.GetStats"
+
+	if err := ctx.Err(); err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	link, exists := s.links[alias]
+	if !exists {
+		return storage.Stats{}, storage.ErrNotFound
+	}
+
+	perDay := make(map[string]int64)
+	for _, click := range s.clicks[link.ID] {
+		perDay[click.At.UTC().Format(time.DateOnly)]++
+	}
+
+	stats := storage.Stats{Total: int64(len(s.clicks[link.ID]))}
+	for _, day := range slices.Sorted(maps.Keys(perDay)) {
+		stats.Daily = append(stats.Daily, storage.DailyClicks{Day: day, Clicks: perDay[day]})
+	}
+
+	return stats, nil
+}
+
+// ListURLs returns a page of links matching params.
+func (s *Storage) ListURLs(ctx context.Context, params storage.ListParams) ([]storage.Link, error) {
+	const op = "storage.memory.ListURLs"
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var links []storage.Link
+	for _, link := range s.links {
+		switch {
+		case params.Cursor > 0 && params.Desc && link.ID >= params.Cursor:
+		case params.Cursor > 0 && !params.Desc && link.ID <= params.Cursor:
+		case !strings.HasPrefix(link.Alias, params.AliasPrefix):
+		case !strings.Contains(link.URL, params.URLContains):
+		default:
+			links = append(links, s.withClicks(link))
+		}
+	}
+
+	slices.SortFunc(links, func(a, b storage.Link) int {
+		if params.Desc {
+			return cmp.Compare(b.ID, a.ID)
+		}
+		return cmp.Compare(a.ID, b.ID)
+	})
+
+	if len(links) > params.Limit {
+		links = links[:params.Limit]
+	}
+
+	return links, nil
+}
+
+// withClicks fills the read-only click counter of link. Callers must hold s.mu.
+func (s *Storage) withClicks(link storage.Link) storage.Link {
+	link.Clicks = int64(len(s.clicks[link.ID]))
+	return link
+}
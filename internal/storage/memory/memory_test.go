@@ -0,0 +1,14 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/zulerne/url-shortener/internal/storage/memory"
+	"github.com/zulerne/url-shortener/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.RunConformance(t, func(t *testing.T) storagetest.Storage {
+		return memory.New()
+	})
+}
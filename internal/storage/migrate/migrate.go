@@ -0,0 +1,316 @@
+// Package migrate applies ordered, versioned SQL migrations and records
+// them in the schema_migrations table.
+//
+// Migrations are read from an fs.FS containing pairs of files named
+// NNNN_description.up.sql and NNNN_description.down.sql. Every migration
+// runs in its own transaction together with its schema_migrations update.
+// With WithLock, Up and Down hold a lock across processes, so that instances
+// starting together do not apply the same migration twice.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNoMigrations = errors.New("no migrations applied")
+	ErrNoDown       = errors.New("migration has no down script")
+)
+
+var fileNameRe = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a single schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status describes whether a migration has been applied.
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	lock       string
+	unlock     string
+}
+
+// Option configures a Migrator.
+type Option func(*Migrator)
+
+// WithLock makes Up and Down run the lock statement before reading the applied
+// migrations and the unlock statement when they are done. Both run on the
+// connection the migrations are applied on, as session level locks like
+// postgres advisory locks require. By default nothing is locked.
+func WithLock(lock, unlock string) Option {
+	return func(m *Migrator) {
+		m.lock = lock
+		m.unlock = unlock
+	}
+}
+
+// conn is what migrations are applied on, *sql.DB or the *sql.Conn holding the lock.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+// New loads migrations from fsys. The SQL in schema_migrations queries uses
+// $N placeholders, which both sqlite and postgres understand.
+func New(db *sql.DB, fsys fs.FS, opts ...Option) (*Migrator, error) {
+	const op = "storage.migrate.New"
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileNameRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("%s: invalid migration file name %q", op, entry.Name())
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid migration version %q: %w", op, entry.Name(), err)
+		}
+
+		script, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("%s: version %d is used by %q and %q", op, version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("%s: migration %d has no up script", op, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	m := &Migrator{db: db, migrations: migrations}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m, nil
+}
+
+// Up applies every pending migration in version order.
+func (m *Migrator) Up(ctx context.Context) error {
+	const op = "storage.migrate.Up"
+
+	c, release, err := m.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer release()
+
+	// Read under the lock, so that migrations applied meanwhile by others are skipped
+	applied, err := m.applied(ctx, c)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+
+		slog.Info("applying migration", "version", migration.Version, "name", migration.Name)
+
+		err := m.inTx(ctx, c, migration.Up,
+			`INSERT INTO schema_migrations(version) VALUES($1)`, migration.Version)
+		if err != nil {
+			return fmt.Errorf("%s: migration %d_%s: %w", op, migration.Version, migration.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// Down rolls back the most recently applied migration.
+func (m *Migrator) Down(ctx context.Context) error {
+	const op = "storage.migrate.Down"
+
+	c, release, err := m.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer release()
+
+	applied, err := m.applied(ctx, c)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+
+		if migration.Down == "" {
+			return fmt.Errorf("%s: migration %d_%s: %w", op, migration.Version, migration.Name, ErrNoDown)
+		}
+
+		slog.Info("rolling back migration", "version", migration.Version, "name", migration.Name)
+
+		err := m.inTx(ctx, c, migration.Down,
+			`DELETE FROM schema_migrations WHERE version = $1`, migration.Version)
+		if err != nil {
+			return fmt.Errorf("%s: migration %d_%s: %w", op, migration.Version, migration.Name, err)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("%s: %w", op, ErrNoMigrations)
+}
+
+// Status reports every known migration in version order.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	const op = "storage.migrate.Status"
+
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, Status{
+			Migration: migration,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+
+	return statuses, nil
+}
+
+// acquire takes the lock of WithLock and returns the connection holding it
+// together with the function releasing it. Without lock it returns the pool.
+func (m *Migrator) acquire(ctx context.Context) (conn, func(), error) {
+	if m.lock == "" {
+		return m.db, func() {}, nil
+	}
+
+	c, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("get connection: %w", err)
+	}
+
+	if _, err := c.ExecContext(ctx, m.lock); err != nil {
+		c.Close()
+		return nil, nil, fmt.Errorf("acquire lock: %w", err)
+	}
+
+	release := func() {
+		// Released even if ctx is done, the connection goes back to the pool
+		if _, err := c.ExecContext(context.WithoutCancel(ctx), m.unlock); err != nil {
+			slog.Error("failed to release migration lock", "error", err)
+			// Discarding the connection ends its session and with it the lock
+			c.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		c.Close()
+	}
+
+	return c, release, nil
+}
+
+// applied creates schema_migrations if needed and returns applied versions
+// with the time they were applied.
+func (m *Migrator) applied(ctx context.Context, c conn) (map[int64]time.Time, error) {
+	_, err := c.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations(
+		version BIGINT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := c.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("query schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var (
+			version   int64
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[version] = appliedAt
+	}
+
+	return applied, rows.Err()
+}
+
+// inTx runs script and the schema_migrations bookkeeping query atomically.
+func (m *Migrator) inTx(ctx context.Context, c conn, script string, query string, version int64) error {
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("execute script: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, query, version); err != nil {
+		return fmt.Errorf("update schema_migrations: %w", err)
+	}
+
+	return tx.Commit()
+}
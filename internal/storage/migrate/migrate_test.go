@@ -0,0 +1,184 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/storage/migrate"
+)
+
+func newDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "migrate.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?`, name).Scan(&count)
+	require.NoError(t, err)
+
+	return count > 0
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	fsys := fstest.MapFS{
+		"0002_create_b.up.sql":   {Data: []byte(`CREATE TABLE b(id INTEGER);`)},
+		"0002_create_b.down.sql": {Data: []byte(`DROP TABLE b;`)},
+		"0001_create_a.up.sql":   {Data: []byte(`CREATE TABLE a(id INTEGER);`)},
+		"0001_create_a.down.sql": {Data: []byte(`DROP TABLE a;`)},
+	}
+
+	m, err := migrate.New(db, fsys)
+	require.NoError(t, err)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.Equal(t, int64(1), statuses[0].Version)
+	require.Equal(t, "create_a", statuses[0].Name)
+	require.False(t, statuses[0].Applied)
+	require.False(t, statuses[1].Applied)
+
+	require.NoError(t, m.Up(ctx))
+	require.True(t, tableExists(t, db, "a"))
+	require.True(t, tableExists(t, db, "b"))
+
+	// Up is idempotent.
+	require.NoError(t, m.Up(ctx))
+
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, statuses[0].Applied)
+	require.True(t, statuses[1].Applied)
+	require.False(t, statuses[1].AppliedAt.IsZero())
+
+	// Down rolls back one migration at a time, newest first.
+	require.NoError(t, m.Down(ctx))
+	require.True(t, tableExists(t, db, "a"))
+	require.False(t, tableExists(t, db, "b"))
+
+	require.NoError(t, m.Down(ctx))
+	require.False(t, tableExists(t, db, "a"))
+
+	require.ErrorIs(t, m.Down(ctx), migrate.ErrNoMigrations)
+}
+
+func TestMigratorFailedMigrationIsRolledBack(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	fsys := fstest.MapFS{
+		"0001_broken.up.sql": {Data: []byte(`CREATE TABLE a(id INTEGER); INSERT INTO missing VALUES(1);`)},
+	}
+
+	m, err := migrate.New(db, fsys)
+	require.NoError(t, err)
+
+	require.Error(t, m.Up(ctx))
+	require.False(t, tableExists(t, db, "a"))
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.False(t, statuses[0].Applied)
+}
+
+func TestMigratorNoDown(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	fsys := fstest.MapFS{
+		"0001_create_a.up.sql": {Data: []byte(`CREATE TABLE a(id INTEGER);`)},
+	}
+
+	m, err := migrate.New(db, fsys)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Up(ctx))
+	require.ErrorIs(t, m.Down(ctx), migrate.ErrNoDown)
+}
+
+func TestMigratorLock(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	// Temp tables are visible to their connection only, so the scripts
+	// succeed only on the connection holding the lock
+	fsys := fstest.MapFS{
+		"0001_create_a.up.sql":   {Data: []byte(`INSERT INTO temp.migration_lock VALUES(1); CREATE TABLE a(id INTEGER);`)},
+		"0001_create_a.down.sql": {Data: []byte(`INSERT INTO temp.migration_lock VALUES(1); DROP TABLE a;`)},
+	}
+
+	m, err := migrate.New(db, fsys, migrate.WithLock(
+		`CREATE TEMP TABLE migration_lock(id INTEGER)`,
+		`DROP TABLE temp.migration_lock`,
+	))
+	require.NoError(t, err)
+
+	// Taking the lock again fails unless it was released
+	require.NoError(t, m.Up(ctx))
+	require.True(t, tableExists(t, db, "a"))
+	require.NoError(t, m.Up(ctx))
+
+	require.NoError(t, m.Down(ctx))
+	require.False(t, tableExists(t, db, "a"))
+}
+
+func TestMigratorLockFails(t *testing.T) {
+	ctx := context.Background()
+	db := newDB(t)
+
+	fsys := fstest.MapFS{
+		"0001_create_a.up.sql": {Data: []byte(`CREATE TABLE a(id INTEGER);`)},
+	}
+
+	m, err := migrate.New(db, fsys, migrate.WithLock(`SELECT * FROM missing`, `SELECT 1`))
+	require.NoError(t, err)
+
+	require.Error(t, m.Up(ctx))
+	require.False(t, tableExists(t, db, "a"))
+}
+
+func TestNewInvalidFiles(t *testing.T) {
+	cases := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "Invalid name",
+			fsys: fstest.MapFS{"create_a.sql": {}},
+		},
+		{
+			name: "Missing up",
+			fsys: fstest.MapFS{"0001_create_a.down.sql": {Data: []byte(`DROP TABLE a;`)}},
+		},
+		{
+			name: "Duplicate version",
+			fsys: fstest.MapFS{
+				"0001_create_a.up.sql": {Data: []byte(`CREATE TABLE a(id INTEGER);`)},
+				"0001_create_b.up.sql": {Data: []byte(`CREATE TABLE b(id INTEGER);`)},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := migrate.New(nil, tc.fsys)
+			require.Error(t, err)
+		})
+	}
+}
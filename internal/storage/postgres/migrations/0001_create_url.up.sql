@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS url(
+	id BIGSERIAL PRIMARY KEY,
+	alias TEXT UNIQUE NOT NULL,
+	url TEXT NOT NULL
+);
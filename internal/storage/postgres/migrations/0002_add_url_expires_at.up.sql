@@ -0,0 +1,2 @@
+ALTER TABLE url ADD COLUMN expires_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_url_expires_at ON url(expires_at);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_clicks_url_id;
+DROP TABLE IF EXISTS clicks;
@@ -0,0 +1 @@
+ALTER TABLE url ADD COLUMN updated_at TIMESTAMPTZ;
@@ -0,0 +1 @@
+ALTER TABLE url ADD COLUMN created_by TEXT NOT NULL DEFAULT '';
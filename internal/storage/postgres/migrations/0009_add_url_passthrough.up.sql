@@ -0,0 +1,2 @@
+ALTER TABLE url ADD COLUMN forward_query BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE url ADD COLUMN prefix BOOLEAN NOT NULL DEFAULT false;
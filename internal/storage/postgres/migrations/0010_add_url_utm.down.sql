@@ -0,0 +1,5 @@
+ALTER TABLE url DROP COLUMN utm_content;
+ALTER TABLE url DROP COLUMN utm_term;
+ALTER TABLE url DROP COLUMN utm_campaign;
+ALTER TABLE url DROP COLUMN utm_medium;
+ALTER TABLE url DROP COLUMN utm_source;
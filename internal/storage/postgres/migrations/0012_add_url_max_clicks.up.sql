@@ -0,0 +1,2 @@
+ALTER TABLE url ADD COLUMN max_clicks BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE url ADD COLUMN clicks_used BIGINT NOT NULL DEFAULT 0;
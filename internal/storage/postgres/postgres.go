@@ -0,0 +1,577 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/zulerne/url-shortener/internal/storage"
+	"github.com/zulerne/url-shortener/internal/storage/migrate"
+)
+
+// uniqueViolation is the SQLSTATE code reported when a UNIQUE constraint fails.
+const uniqueViolation = "23505"
+
+// migrationLockID is the key of the advisory lock held while migrating,
+// so that replicas starting together apply every migration once.
+const migrationLockID = 7_349_801_652
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+type Storage struct {
+	db *sql.DB
+}
+
+// New connects to the database and applies pending migrations.
+func New(dsn string) (*Storage, error) {
+	const op = "storage.postgres.New"
+
+	db, err := Open(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	m, err := NewMigrator(db)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = m.Up(context.Background()); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Storage{db}, nil
+}
+
+// Open connects to the database without touching its schema.
+func Open(dsn string) (*sql.DB, error) {
+	const op = "storage.postgres.Open"
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = db.Ping(); err != nil {
+		return nil, fmt.Errorf("%s: ping: %w", op, err)
+	}
+
+	return db, nil
+}
+
+// NewMigrator returns a migrator for the embedded postgres migrations.
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(db, fsys, migrate.WithLock(
+		fmt.Sprintf("SELECT pg_advisory_lock(%d)", migrationLockID),
+		fmt.Sprintf("SELECT pg_advisory_unlock(%d)", migrationLockID),
+	))
+}
+
+func (s *Storage) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
+	const op = "storage.postgres.SaveURL"
+
+	// Custom aliases are not deduplicated
+	link.NormalizedURL = ""
+
+	var id int64
+	err := s.db.QueryRowContext(ctx,
+		`INSERT INTO url(`+insertColumns+`) VALUES(`+insertValues+`) RETURNING id`,
+		insertArgs(link)...,
+	).Scan(&id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrAliasExists)
+		}
+
+		return 0, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SaveGeneratedURL saves link under an alias generated by aliasOf and returns it with
+// its ID and Alias set. If another link has the same NormalizedURL, nothing is saved
+// and that link is returned with created set to false.
+func (s *Storage) SaveGeneratedURL(ctx context.Context, link storage.Link, aliasOf storage.AliasFunc) (storage.Link, bool, error) {
+	const op = "storage.postgres.SaveGeneratedURL"
+
+	link, created, err := insertGenerated(ctx, s.db, link, aliasOf)
+	if err != nil {
+		return storage.Link{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return link, created, nil
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// insertGenerated takes the id of link from the sequence first, because generated aliases
+// may depend on it. Taken aliases are retried with a fresh id, so they change too.
+// A link with the same NormalizedURL is returned instead of inserting a duplicate.
+func insertGenerated(ctx context.Context, q queryer, link storage.Link, aliasOf storage.AliasFunc) (storage.Link, bool, error) {
+	for attempt := range storage.MaxAliasAttempts {
+		var id int64
+		err := q.QueryRowContext(ctx, `SELECT nextval(pg_get_serial_sequence('url', 'id'))`).Scan(&id)
+		if err != nil {
+			return storage.Link{}, false, fmt.Errorf("next id: %w", err)
+		}
+
+		alias := aliasOf(id, attempt)
+		if alias == "" {
+			continue
+		}
+
+		// A failed statement aborts a postgres transaction, so conflicts must not raise errors
+		link.Alias = alias
+		err = q.QueryRowContext(ctx, `
+		INSERT INTO url(id, `+insertColumns+`) VALUES($17, `+insertValues+`)
+		ON CONFLICT DO NOTHING
+		RETURNING id, created_at
+		`, append(insertArgs(link), id)...).Scan(&link.ID, &link.CreatedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			if link.NormalizedURL == "" {
+				continue
+			}
+
+			// The conflict was either on the alias or on the normalized url
+			existing, err := scanLink(q.QueryRowContext(ctx, `SELECT `+linkColumns+` FROM url WHERE normalized_url = $1`, link.NormalizedURL))
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				return storage.Link{}, false, fmt.Errorf("get existing: %w", err)
+			}
+			return existing, false, nil
+		}
+		if err != nil {
+			return storage.Link{}, false, fmt.Errorf("insert: %w", err)
+		}
+
+		return link, true, nil
+	}
+
+	return storage.Link{}, false, storage.ErrAliasExists
+}
+
+// SaveURLs saves links in a single transaction. Links without an alias get one from aliasOf
+// and are deduplicated like in SaveGeneratedURL. A taken alias fails only its own link,
+// any other error rolls back the whole batch.
+func (s *Storage) SaveURLs(ctx context.Context, links []storage.Link, aliasOf storage.AliasFunc) ([]storage.SaveResult, error) {
+	const op = "storage.postgres.SaveURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	// A failed statement aborts a postgres transaction, so conflicts must not raise errors
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO url(`+insertColumns+`) VALUES(`+insertValues+`)
+	ON CONFLICT (alias) DO NOTHING
+	RETURNING id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer stmt.Close()
+
+	results := make([]storage.SaveResult, len(links))
+	for i, link := range links {
+		if link.Alias == "" {
+			saved, created, err := insertGenerated(ctx, tx, link, aliasOf)
+			if err != nil && !errors.Is(err, storage.ErrAliasExists) {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			results[i] = storage.SaveResult{ID: saved.ID, Alias: saved.Alias, Reused: err == nil && !created, Err: err}
+			continue
+		}
+
+		results[i].Alias = link.Alias
+		link.NormalizedURL = ""
+		err = stmt.QueryRowContext(ctx, insertArgs(link)...).Scan(&results[i].ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				results[i].Err = storage.ErrAliasExists
+				continue
+			}
+			return nil, fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return results, nil
+}
+
+// GetURL returns the link of alias for redirecting. It fails with storage.ErrExpired
+// for expired links and storage.ErrExhausted for links without clicks left. It does not count clicks.
+func (s *Storage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.postgres.GetURL"
+
+	link, err := scanLink(s.db.QueryRowContext(ctx, `SELECT `+urlColumns+`, 0 FROM url WHERE alias = $1`, alias))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Link{}, storage.ErrNotFound
+		}
+		return storage.Link{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	if link.Expired(time.Now()) {
+		return storage.Link{}, storage.ErrExpired
+	}
+	if link.Exhausted() {
+		return storage.Link{}, storage.ErrExhausted
+	}
+
+	return link, nil
+}
+
+// GetLink returns the full record of an alias, including expired ones.
+func (s *Storage) GetLink(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.postgres.GetLink"
+
+	link, err := scanLink(s.db.QueryRowContext(ctx, `SELECT `+linkColumns+` FROM url WHERE alias = $1`, alias))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Link{}, storage.ErrNotFound
+		}
+		return storage.Link{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	return link, nil
+}
+
+// ConsumeClick uses up a click of a link with MaxClicks, so that it fails with storage.ErrExhausted
+// once none are left. Concurrent calls never use more clicks than allowed. Links without MaxClicks
+// are not counted.
+func (s *Storage) ConsumeClick(ctx context.Context, alias string) error {
+	const op = "storage.postgres.ConsumeClick"
+
+	res, err := s.db.ExecContext(ctx, `
+	UPDATE url SET clicks_used = clicks_used + 1
+	WHERE alias = $1 AND max_clicks > 0 AND clicks_used < max_clicks
+	`, alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected %w", op, err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	// Nothing was used up, because the link is unlimited, exhausted or missing
+	var maxClicks int64
+	err = s.db.QueryRowContext(ctx, `SELECT max_clicks FROM url WHERE alias = $1`, alias).Scan(&maxClicks)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	if maxClicks > 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrExhausted)
+	}
+
+	return nil
+}
+
+// UpdateURL changes the destination of an existing alias.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, urlToSave string) error {
+	const op = "storage.postgres.UpdateURL"
+
+	res, err := s.db.ExecContext(ctx, `UPDATE url SET url = $1, updated_at = now(), normalized_url = NULL WHERE alias = $2`, urlToSave, alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected %w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdateUTM replaces the UTM tags of an existing alias.
+func (s *Storage) UpdateUTM(ctx context.Context, alias string, utm storage.UTM) error {
+	const op = "storage.postgres.UpdateUTM"
+
+	// Tagged links are not deduplicated
+	res, err := s.db.ExecContext(ctx, `
+	UPDATE url SET utm_source = $1, utm_medium = $2, utm_campaign = $3, utm_term = $4, utm_content = $5, updated_at = now(), normalized_url = NULL
+	WHERE alias = $6
+	`, utm.Source, utm.Medium, utm.Campaign, utm.Term, utm.Content, alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected %w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteURL(ctx context.Context, alias string) error {
+	const op = "storage.postgres.DeleteURL"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM url WHERE alias = $1`, alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected %w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteExpiredURLs removes urls that expired at or before now
+// and returns how many were removed.
+func (s *Storage) DeleteExpiredURLs(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.postgres.DeleteExpiredURLs"
+
+	res, err := s.db.ExecContext(ctx, `DELETE FROM url WHERE expires_at IS NOT NULL AND expires_at <= $1`, now)
+	if err != nil {
+		return 0, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to get rows affected %w", op, err)
+	}
+
+	return affected, nil
+}
+
+// SaveClicks stores a batch of clicks in a single transaction.
+// Clicks of aliases that no longer exist are skipped.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const op = "storage.postgres.SaveClicks"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO clicks(url_id, clicked_at, referrer, user_agent, request_id)
+	SELECT id, $1, $2, $3, $4 FROM url WHERE alias = $5
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer stmt.Close()
+
+	for _, click := range clicks {
+		_, err = stmt.ExecContext(ctx, click.At, click.Referrer, click.UserAgent, click.RequestID, click.Alias)
+		if err != nil {
+			return fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return nil
+}
+
+func (s *Storage) GetStats(ctx context.Context, alias string) (storage.Stats, error) {
+	const op = "storage.postgres.GetStats"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM url WHERE alias = $1`, alias).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Stats{}, storage.ErrNotFound
+		}
+		return storage.Stats{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT to_char(clicked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, COUNT(*) FROM clicks
+	WHERE url_id = $1
+	GROUP BY day
+	ORDER BY day
+	`, id)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	defer rows.Close()
+
+	var stats storage.Stats
+	for rows.Next() {
+		var daily storage.DailyClicks
+		if err = rows.Scan(&daily.Day, &daily.Clicks); err != nil {
+			return storage.Stats{}, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		stats.Total += daily.Clicks
+		stats.Daily = append(stats.Daily, daily)
+	}
+	if err = rows.Err(); err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// ListURLs returns a page of links matching params.
+func (s *Storage) ListURLs(ctx context.Context, params storage.ListParams) ([]storage.Link, error) {
+	const op = "storage.postgres.ListURLs"
+
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if params.Cursor > 0 {
+		if params.Desc {
+			where = append(where, "id < "+arg(params.Cursor))
+		} else {
+			where = append(where, "id > "+arg(params.Cursor))
+		}
+	}
+	if params.AliasPrefix != "" {
+		where = append(where, "starts_with(alias, "+arg(params.AliasPrefix)+")")
+	}
+	if params.URLContains != "" {
+		where = append(where, "strpos(url, "+arg(params.URLContains)+") > 0")
+	}
+
+	query := `SELECT ` + linkColumns + ` FROM url`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	if params.Desc {
+		query += ` ORDER BY id DESC`
+	} else {
+		query += ` ORDER BY id`
+	}
+	query += ` LIMIT ` + arg(params.Limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	defer rows.Close()
+
+	var links []storage.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		links = append(links, link)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return links, nil
+}
+
+// linkColumns are selected by every query that returns a storage.Link, see scanLink.
+const linkColumns = urlColumns + `, (SELECT COUNT(*) FROM clicks WHERE clicks.url_id = url.id)`
+
+// urlColumns are the columns of the url table in linkColumns.
+const urlColumns = `id, alias, url, expires_at, updated_at, created_at, created_by, normalized_url, redirect_type, forward_query, prefix,
+	utm_source, utm_medium, utm_campaign, utm_term, utm_content, password_hash, max_clicks, clicks_used, valid_from`
+
+// insertColumns are set by every insert of a link, with the values of insertArgs.
+// created_at defaults to now().
+const (
+	insertColumns = `alias, url, expires_at, created_by, normalized_url, redirect_type, forward_query, prefix,
+	utm_source, utm_medium, utm_campaign, utm_term, utm_content, password_hash, max_clicks, valid_from`
+	insertValues = `$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16`
+)
+
+func insertArgs(link storage.Link) []any {
+	return []any{
+		link.Alias, link.URL, nullTime(link.ExpiresAt), link.CreatedBy,
+		nullString(link.NormalizedURL), link.RedirectType, link.ForwardQuery, link.Prefix,
+		link.UTM.Source, link.UTM.Medium, link.UTM.Campaign, link.UTM.Term, link.UTM.Content, link.PasswordHash,
+		link.MaxClicks, nullTime(link.ValidFrom),
+	}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLink(row scanner) (storage.Link, error) {
+	var (
+		link                                       storage.Link
+		expiresAt, updatedAt, createdAt, validFrom sql.NullTime
+		normalizedURL                              sql.NullString
+	)
+
+	err := row.Scan(&link.ID, &link.Alias, &link.URL, &expiresAt, &updatedAt, &createdAt, &link.CreatedBy, &normalizedURL, &link.RedirectType, &link.ForwardQuery, &link.Prefix,
+		&link.UTM.Source, &link.UTM.Medium, &link.UTM.Campaign, &link.UTM.Term, &link.UTM.Content, &link.PasswordHash,
+		&link.MaxClicks, &link.ClicksUsed, &validFrom, &link.Clicks)
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	link.ExpiresAt = expiresAt.Time
+	link.ValidFrom = validFrom.Time
+	link.UpdatedAt = updatedAt.Time
+	link.CreatedAt = createdAt.Time
+	link.NormalizedURL = normalizedURL.String
+
+	return link, nil
+}
+
+// nullString maps the empty string to NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullTime maps the zero time to NULL.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
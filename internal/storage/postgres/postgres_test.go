@@ -0,0 +1,90 @@
+package postgres_test
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/storage/postgres"
+	"github.com/zulerne/url-shortener/internal/storage/storagetest"
+)
+
+// TestConformance runs against the database from POSTGRES_TEST_DSN,
+// e.g. the one started by `make test-postgres`.
+func TestConformance(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	s, err := postgres.New(dsn)
+	require.NoError(t, err)
+
+	storagetest.RunConformance(t, func(t *testing.T) storagetest.Storage {
+		return s
+	})
+}
+
+// TestConcurrentMigrations starts replicas together on an empty schema,
+// each of them must come up without applying a migration twice.
+func TestConcurrentMigrations(t *testing.T) {
+	dsn := os.Getenv("POSTGRES_TEST_DSN")
+	if dsn == "" {
+		t.Skip("POSTGRES_TEST_DSN is not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	schema := fmt.Sprintf("migrate_%d", time.Now().UnixNano())
+	_, err = db.Exec(`CREATE SCHEMA ` + schema)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		db.Exec(`DROP SCHEMA ` + schema + ` CASCADE`)
+	})
+
+	// Unknown parameters are sent to the server as run-time parameters
+	if strings.Contains(dsn, "://") {
+		sep := "?"
+		if strings.Contains(dsn, "?") {
+			sep = "&"
+		}
+		dsn += sep + "search_path=" + schema
+	} else {
+		dsn += " search_path=" + schema
+	}
+
+	errs := make([]error, 5)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Go(func() {
+			errs[i] = migrateUp(t, dsn)
+		})
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func migrateUp(t *testing.T, dsn string) error {
+	db, err := postgres.Open(dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	m, err := postgres.NewMigrator(db)
+	if err != nil {
+		return err
+	}
+
+	return m.Up(t.Context())
+}
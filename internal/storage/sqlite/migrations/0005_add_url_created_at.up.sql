@@ -0,0 +1,2 @@
+-- sqlite cannot add a column with a non-constant default, rows created before this migration keep NULL
+ALTER TABLE url ADD COLUMN created_at DATETIME;
@@ -0,0 +1,2 @@
+ALTER TABLE url ADD COLUMN normalized_url TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_url_normalized_url ON url(normalized_url);
@@ -0,0 +1,2 @@
+ALTER TABLE url DROP COLUMN prefix;
+ALTER TABLE url DROP COLUMN forward_query;
@@ -0,0 +1,2 @@
+ALTER TABLE url ADD COLUMN forward_query INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE url ADD COLUMN prefix INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1 @@
+ALTER TABLE url ADD COLUMN valid_from DATETIME;
@@ -0,0 +1,49 @@
+-- Ids of deleted links may be reused again.
+
+-- Dropping url would cascade to clicks, so clicks are rebuilt too. Renaming url_new
+-- updates the reference of clicks_new.
+CREATE TABLE url_new(
+	id INTEGER PRIMARY KEY,
+	alias TEXT UNIQUE NOT NULL,
+	url TEXT NOT NULL,
+	expires_at DATETIME,
+	updated_at DATETIME,
+	created_at DATETIME,
+	created_by TEXT NOT NULL DEFAULT '',
+	normalized_url TEXT,
+	redirect_type INTEGER NOT NULL DEFAULT 0,
+	forward_query INTEGER NOT NULL DEFAULT 0,
+	prefix INTEGER NOT NULL DEFAULT 0,
+	utm_source TEXT NOT NULL DEFAULT '',
+	utm_medium TEXT NOT NULL DEFAULT '',
+	utm_campaign TEXT NOT NULL DEFAULT '',
+	utm_term TEXT NOT NULL DEFAULT '',
+	utm_content TEXT NOT NULL DEFAULT '',
+	password_hash TEXT NOT NULL DEFAULT '',
+	max_clicks INTEGER NOT NULL DEFAULT 0,
+	clicks_used INTEGER NOT NULL DEFAULT 0,
+	valid_from DATETIME
+);
+INSERT INTO url_new(id, alias, url, expires_at, updated_at, created_at, created_by, normalized_url, redirect_type, forward_query, prefix, utm_source, utm_medium, utm_campaign, utm_term, utm_content, password_hash, max_clicks, clicks_used, valid_from)
+SELECT id, alias, url, expires_at, updated_at, created_at, created_by, normalized_url, redirect_type, forward_query, prefix, utm_source, utm_medium, utm_campaign, utm_term, utm_content, password_hash, max_clicks, clicks_used, valid_from FROM url;
+
+CREATE TABLE clicks_new(
+	id INTEGER PRIMARY KEY,
+	url_id INTEGER NOT NULL REFERENCES url_new(id) ON DELETE CASCADE,
+	clicked_at DATETIME NOT NULL,
+	referrer TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	request_id TEXT NOT NULL DEFAULT ''
+);
+INSERT INTO clicks_new(id, url_id, clicked_at, referrer, user_agent, request_id)
+SELECT id, url_id, clicked_at, referrer, user_agent, request_id FROM clicks;
+
+DROP TABLE clicks;
+DROP TABLE url;
+ALTER TABLE url_new RENAME TO url;
+ALTER TABLE clicks_new RENAME TO clicks;
+
+CREATE INDEX idx_alias ON url(alias);
+CREATE INDEX idx_url_expires_at ON url(expires_at);
+CREATE UNIQUE INDEX idx_url_normalized_url ON url(normalized_url);
+CREATE INDEX idx_clicks_url_id ON clicks(url_id, clicked_at);
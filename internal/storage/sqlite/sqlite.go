@@ -1,57 +1,92 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
+	"strings"
+	"time"
 
 	"github.com/mattn/go-sqlite3"
 	"github.com/zulerne/url-shortener/internal/storage"
+	"github.com/zulerne/url-shortener/internal/storage/migrate"
 )
 
+//go:embed migrations/*.sql
+var migrations embed.FS
+
 type Storage struct {
 	db *sql.DB
 }
 
+// New opens the database and applies pending migrations.
 func New(storagePath string) (*Storage, error) {
 	const op = "storage.sqlite.New"
 
-	db, err := sql.Open("sqlite3", storagePath)
+	db, err := Open(storagePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
+	m, err := NewMigrator(db)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	stmt, err := db.Prepare(`
-	CREATE TABLE IF NOT EXISTS url(
-	  	id INTEGER PRIMARY KEY,  
-	  	alias TEXT UNIQUE NOT NULL,  
-	  	url TEXT NOT NULL
-	);
-	CREATE INDEX IF NOT EXISTS idx_alias ON url(alias);
-	`)
+	if err = m.Up(context.Background()); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
 
-	_, err = stmt.Exec()
+	return &Storage{db}, nil
+}
 
+// Open opens the database without touching its schema.
+func Open(storagePath string) (*sql.DB, error) {
+	const op = "storage.sqlite.Open"
+
+	// Foreign keys are disabled by default and have to be enabled per connection
+	dsn := storagePath
+	if strings.Contains(dsn, "?") {
+		dsn += "&_foreign_keys=on"
+	} else {
+		dsn += "?_foreign_keys=on"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	return &Storage{db}, nil
+	return db, nil
+}
+
+// NewMigrator returns a migrator for the embedded sqlite migrations.
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	fsys, err := fs.Sub(migrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return migrate.New(db, fsys)
 }
 
-func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
+func (s *Storage) SaveURL(ctx context.Context, link storage.Link) (int64, error) {
 	const op = "storage.sqlite.SaveURL"
 
-	stmt, err := s.db.Prepare(`INSERT INTO url(alias, url) VALUES(?, ?)`)
+	stmt, err := s.db.PrepareContext(ctx, `INSERT INTO url(`+insertColumns+`) VALUES(`+insertValues+`)`)
 	if err != nil {
 		return 0, fmt.Errorf("%s: prepare statement: %w", op, err)
 	}
+	defer stmt.Close()
 
-	res, err := stmt.Exec(alias, urlToSave)
+	// Custom aliases are not deduplicated
+	link.NormalizedURL = ""
+	res, err := stmt.ExecContext(ctx, insertArgs(link, time.Now().UTC())...)
 	if err != nil {
-		var sqliteErr sqlite3.Error
-		if errors.As(err, &sqliteErr) && (errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique)) {
+		if isUniqueViolation(err) {
 			return 0, fmt.Errorf("%s: %w", op, storage.ErrAliasExists)
 		}
 
@@ -66,41 +101,537 @@ func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
 	return id, nil
 }
 
-func (s *Storage) GetURL(alias string) (string, error) {
+// SaveGeneratedURL saves link under an alias generated by aliasOf and returns it with
+// its ID and Alias set. If another link has the same NormalizedURL, nothing is saved
+// and that link is returned with created set to false.
+func (s *Storage) SaveGeneratedURL(ctx context.Context, link storage.Link, aliasOf storage.AliasFunc) (storage.Link, bool, error) {
+	const op = "storage.sqlite.SaveGeneratedURL"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return storage.Link{}, false, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	link, created, err := insertGenerated(ctx, tx, link, time.Now().UTC(), aliasOf)
+	if err != nil {
+		return storage.Link{}, false, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return storage.Link{}, false, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return link, created, nil
+}
+
+// pendingAlias marks a link whose alias is not generated yet.
+// It never outlives the transaction that inserted the link.
+const pendingAlias = "\x00pending"
+
+// insertGenerated inserts link and then sets its alias, because generated aliases may
+// depend on the id. Taken aliases move the link to a fresh id, so they change too.
+// A link with the same NormalizedURL is returned instead of inserting a duplicate.
+func insertGenerated(ctx context.Context, tx *sql.Tx, link storage.Link, now time.Time, aliasOf storage.AliasFunc) (storage.Link, bool, error) {
+	pending := link
+	pending.Alias = pendingAlias
+
+	var id int64
+	err := tx.QueryRowContext(ctx, `
+	INSERT INTO url(`+insertColumns+`) VALUES(`+insertValues+`)
+	ON CONFLICT DO NOTHING
+	RETURNING id
+	`, insertArgs(pending, now)...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The pending alias is never committed, so the normalized url is taken
+		existing, err := scanLink(tx.QueryRowContext(ctx, `SELECT `+linkColumns+` FROM url WHERE normalized_url = ?`, link.NormalizedURL))
+		if err != nil {
+			return storage.Link{}, false, fmt.Errorf("get existing: %w", err)
+		}
+		return existing, false, nil
+	}
+	if err != nil {
+		return storage.Link{}, false, fmt.Errorf("insert: %w", err)
+	}
+
+	for attempt := range storage.MaxAliasAttempts {
+		if alias := aliasOf(id, attempt); alias != "" {
+			_, err = tx.ExecContext(ctx, `UPDATE url SET alias = ? WHERE id = ?`, alias, id)
+			if err == nil {
+				link.ID = id
+				link.Alias = alias
+				link.CreatedAt = now
+				return link, true, nil
+			}
+			if !isUniqueViolation(err) {
+				return storage.Link{}, false, fmt.Errorf("set alias: %w", err)
+			}
+		}
+
+		// Only inserts advance the sequence, so the new id is taken from it by hand
+		var next int64
+		err = tx.QueryRowContext(ctx, `UPDATE sqlite_sequence SET seq = seq + 1 WHERE name = 'url' RETURNING seq`).Scan(&next)
+		if err != nil {
+			return storage.Link{}, false, fmt.Errorf("move to new id: %w", err)
+		}
+		if _, err = tx.ExecContext(ctx, `UPDATE url SET id = ? WHERE id = ?`, next, id); err != nil {
+			return storage.Link{}, false, fmt.Errorf("move to new id: %w", err)
+		}
+		id = next
+	}
+
+	// The transaction may go on with other links
+	if _, err = tx.ExecContext(ctx, `DELETE FROM url WHERE id = ?`, id); err != nil {
+		return storage.Link{}, false, fmt.Errorf("delete: %w", err)
+	}
+
+	return storage.Link{}, false, storage.ErrAliasExists
+}
+
+// SaveURLs saves links in a single transaction. Links without an alias get one from aliasOf
+// and are deduplicated like in SaveGeneratedURL. A taken alias fails only its own link,
+// any other error rolls back the whole batch.
+func (s *Storage) SaveURLs(ctx context.Context, links []storage.Link, aliasOf storage.AliasFunc) ([]storage.SaveResult, error) {
+	const op = "storage.sqlite.SaveURLs"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO url(`+insertColumns+`) VALUES(`+insertValues+`)
+	ON CONFLICT(alias) DO NOTHING
+	RETURNING id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	results := make([]storage.SaveResult, len(links))
+	for i, link := range links {
+		if link.Alias == "" {
+			saved, created, err := insertGenerated(ctx, tx, link, now, aliasOf)
+			if err != nil && !errors.Is(err, storage.ErrAliasExists) {
+				return nil, fmt.Errorf("%s: %w", op, err)
+			}
+			results[i] = storage.SaveResult{ID: saved.ID, Alias: saved.Alias, Reused: err == nil && !created, Err: err}
+			continue
+		}
+
+		results[i].Alias = link.Alias
+		link.NormalizedURL = ""
+		err = stmt.QueryRowContext(ctx, insertArgs(link, now)...).Scan(&results[i].ID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				results[i].Err = storage.ErrAliasExists
+				continue
+			}
+			return nil, fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return results, nil
+}
+
+// GetURL returns the link of alias for redirecting. It fails with storage.ErrExpired
+// for expired links and storage.ErrExhausted for links without clicks left. It does not count clicks.
+func (s *Storage) GetURL(ctx context.Context, alias string) (storage.Link, error) {
 	const op = "storage.sqlite.GetURL"
 
-	stmt, err := s.db.Prepare(`SELECT url FROM url WHERE alias = ?`)
+	stmt, err := s.db.PrepareContext(ctx, `SELECT `+urlColumns+`, 0 FROM url WHERE alias = ?`)
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer stmt.Close()
+
+	link, err := scanLink(stmt.QueryRowContext(ctx, alias))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Link{}, storage.ErrNotFound
+		}
+		return storage.Link{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	if link.Expired(time.Now()) {
+		return storage.Link{}, storage.ErrExpired
+	}
+	if link.Exhausted() {
+		return storage.Link{}, storage.ErrExhausted
+	}
 
+	return link, nil
+}
+
+// GetLink returns the full record of an alias, including expired ones.
+func (s *Storage) GetLink(ctx context.Context, alias string) (storage.Link, error) {
+	const op = "storage.sqlite.GetLink"
+
+	stmt, err := s.db.PrepareContext(ctx, `SELECT `+linkColumns+` FROM url WHERE alias = ?`)
 	if err != nil {
-		return "", fmt.Errorf("%s: prepare statement: %w", op, err)
+		return storage.Link{}, fmt.Errorf("%s: prepare statement: %w", op, err)
 	}
+	defer stmt.Close()
 
-	var url string
-	err = stmt.QueryRow(alias).Scan(&url)
+	link, err := scanLink(stmt.QueryRowContext(ctx, alias))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return "", storage.ErrNotFound
+			return storage.Link{}, storage.ErrNotFound
 		}
-		return "", fmt.Errorf("%s: execute statement: %w", op, err)
+		return storage.Link{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
 
+	return link, nil
+}
+
+// ConsumeClick uses up a click of a link with MaxClicks, so that it fails with storage.ErrExhausted
+// once none are left. Concurrent calls never use more clicks than allowed. Links without MaxClicks
+// are not counted.
+func (s *Storage) ConsumeClick(ctx context.Context, alias string) error {
+	const op = "storage.sqlite.ConsumeClick"
+
+	res, err := s.db.ExecContext(ctx, `
+	UPDATE url SET clicks_used = clicks_used + 1
+	WHERE alias = ? AND max_clicks > 0 AND clicks_used < max_clicks
+	`, alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
 	}
 
-	return url, nil
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected %w", op, err)
+	}
+	if affected > 0 {
+		return nil
+	}
+
+	// Nothing was used up, because the link is unlimited, exhausted or missing
+	var maxClicks int64
+	err = s.db.QueryRowContext(ctx, `SELECT max_clicks FROM url WHERE alias = ?`, alias).Scan(&maxClicks)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	if maxClicks > 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrExhausted)
+	}
+
+	return nil
 }
 
-func (s *Storage) DeleteURL(alias string) error {
-	const op = "storage.sqlite.DeleteURL"
+// UpdateURL changes the destination of an existing alias.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, urlToSave string) error {
+	const op = "storage.sqlite.UpdateURL"
+
+	// The new destination is not deduplicated
+	stmt, err := s.db.PrepareContext(ctx, `UPDATE url SET url = ?, updated_at = ?, normalized_url = NULL WHERE alias = ?`)
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, urlToSave, time.Now().UTC(), alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected %w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// UpdateUTM replaces the UTM tags of an existing alias.
+func (s *Storage) UpdateUTM(ctx context.Context, alias string, utm storage.UTM) error {
+	const op = "storage.sqlite.UpdateUTM"
 
-	stmt, err := s.db.Prepare(`DELETE FROM url WHERE alias = ?`)
+	// Tagged links are not deduplicated
+	stmt, err := s.db.PrepareContext(ctx, `
+	UPDATE url SET utm_source = ?, utm_medium = ?, utm_campaign = ?, utm_term = ?, utm_content = ?, updated_at = ?, normalized_url = NULL
+	WHERE alias = ?
+	`)
 	if err != nil {
 		return fmt.Errorf("%s: prepare statement: %w", op, err)
 	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, utm.Source, utm.Medium, utm.Campaign, utm.Term, utm.Content, time.Now().UTC(), alias)
+	if err != nil {
+		return fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected %w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+func (s *Storage) DeleteURL(ctx context.Context, alias string) error {
+	const op = "storage.sqlite.DeleteURL"
 
-	_, err = stmt.Exec(alias)
+	stmt, err := s.db.PrepareContext(ctx, `DELETE FROM url WHERE alias = ?`)
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer stmt.Close()
 
+	res, err := stmt.ExecContext(ctx, alias)
 	if err != nil {
 		return fmt.Errorf("%s: execute statement: %w", op, err)
 	}
 
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: failed to get rows affected %w", op, err)
+	}
+
+	if affected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteExpiredURLs removes urls that expired at or before now
+// and returns how many were removed.
+func (s *Storage) DeleteExpiredURLs(ctx context.Context, now time.Time) (int64, error) {
+	const op = "storage.sqlite.DeleteExpiredURLs"
+
+	stmt, err := s.db.PrepareContext(ctx, `DELETE FROM url WHERE expires_at IS NOT NULL AND expires_at <= ?`)
+	if err != nil {
+		return 0, fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer stmt.Close()
+
+	res, err := stmt.ExecContext(ctx, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: failed to get rows affected %w", op, err)
+	}
+
+	return affected, nil
+}
+
+// SaveClicks stores a batch of clicks in a single transaction.
+// Clicks of aliases that no longer exist are skipped.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) error {
+	const op = "storage.sqlite.SaveClicks"
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin transaction: %w", op, err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+	INSERT INTO clicks(url_id, clicked_at, referrer, user_agent, request_id)
+	SELECT id, ?, ?, ?, ? FROM url WHERE alias = ?
+	`)
+	if err != nil {
+		return fmt.Errorf("%s: prepare statement: %w", op, err)
+	}
+	defer stmt.Close()
+
+	for _, click := range clicks {
+		_, err = stmt.ExecContext(ctx, click.At.UTC(), click.Referrer, click.UserAgent, click.RequestID, click.Alias)
+		if err != nil {
+			return fmt.Errorf("%s: execute statement: %w", op, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
 	return nil
 }
+
+func (s *Storage) GetStats(ctx context.Context, alias string) (storage.Stats, error) {
+	const op = "storage.sqlite.GetStats"
+
+	var id int64
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM url WHERE alias = ?`, alias).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.Stats{}, storage.ErrNotFound
+		}
+		return storage.Stats{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT date(clicked_at) AS day, COUNT(*) FROM clicks
+	WHERE url_id = ?
+	GROUP BY day
+	ORDER BY day
+	`, id)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	defer rows.Close()
+
+	var stats storage.Stats
+	for rows.Next() {
+		var daily storage.DailyClicks
+		if err = rows.Scan(&daily.Day, &daily.Clicks); err != nil {
+			return storage.Stats{}, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		stats.Total += daily.Clicks
+		stats.Daily = append(stats.Daily, daily)
+	}
+	if err = rows.Err(); err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// ListURLs returns a page of links matching params.
+func (s *Storage) ListURLs(ctx context.Context, params storage.ListParams) ([]storage.Link, error) {
+	const op = "storage.sqlite.ListURLs"
+
+	var (
+		where []string
+		args  []any
+	)
+
+	if params.Cursor > 0 {
+		if params.Desc {
+			where = append(where, "id < ?")
+		} else {
+			where = append(where, "id > ?")
+		}
+		args = append(args, params.Cursor)
+	}
+	if params.AliasPrefix != "" {
+		where = append(where, "substr(alias, 1, length(?)) = ?")
+		args = append(args, params.AliasPrefix, params.AliasPrefix)
+	}
+	if params.URLContains != "" {
+		where = append(where, "instr(url, ?) > 0")
+		args = append(args, params.URLContains)
+	}
+
+	query := `SELECT ` + linkColumns + ` FROM url`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	if params.Desc {
+		query += ` ORDER BY id DESC`
+	} else {
+		query += ` ORDER BY id`
+	}
+	query += ` LIMIT ?`
+	args = append(args, params.Limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: execute statement: %w", op, err)
+	}
+	defer rows.Close()
+
+	var links []storage.Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		links = append(links, link)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return links, nil
+}
+
+// linkColumns are selected by every query that returns a storage.Link, see scanLink.
+const linkColumns = urlColumns + `, (SELECT COUNT(*) FROM clicks WHERE clicks.url_id = url.id)`
+
+// urlColumns are the columns of the url table in linkColumns.
+const urlColumns = `id, alias, url, expires_at, updated_at, created_at, created_by, normalized_url, redirect_type, forward_query, prefix,
+	utm_source, utm_medium, utm_campaign, utm_term, utm_content, password_hash, max_clicks, clicks_used, valid_from`
+
+// insertColumns are set by every insert of a link, with the values of insertArgs.
+const (
+	insertColumns = `alias, url, expires_at, created_at, created_by, normalized_url, redirect_type, forward_query, prefix,
+	utm_source, utm_medium, utm_campaign, utm_term, utm_content, password_hash, max_clicks, valid_from`
+	insertValues = `?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?`
+)
+
+func insertArgs(link storage.Link, now time.Time) []any {
+	return []any{
+		link.Alias, link.URL, nullTime(link.ExpiresAt), now, link.CreatedBy,
+		nullString(link.NormalizedURL), link.RedirectType, link.ForwardQuery, link.Prefix,
+		link.UTM.Source, link.UTM.Medium, link.UTM.Campaign, link.UTM.Term, link.UTM.Content, link.PasswordHash,
+		link.MaxClicks, nullTime(link.ValidFrom),
+	}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanLink(row scanner) (storage.Link, error) {
+	var (
+		link                                       storage.Link
+		expiresAt, updatedAt, createdAt, validFrom sql.NullTime
+		normalizedURL                              sql.NullString
+	)
+
+	err := row.Scan(&link.ID, &link.Alias, &link.URL, &expiresAt, &updatedAt, &createdAt, &link.CreatedBy, &normalizedURL, &link.RedirectType, &link.ForwardQuery, &link.Prefix,
+		&link.UTM.Source, &link.UTM.Medium, &link.UTM.Campaign, &link.UTM.Term, &link.UTM.Content, &link.PasswordHash,
+		&link.MaxClicks, &link.ClicksUsed, &validFrom, &link.Clicks)
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	link.ExpiresAt = expiresAt.Time
+	link.ValidFrom = validFrom.Time
+	link.UpdatedAt = updatedAt.Time
+	link.CreatedAt = createdAt.Time
+	link.NormalizedURL = normalizedURL.String
+
+	return link, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && errors.Is(sqliteErr.ExtendedCode, sqlite3.ErrConstraintUnique)
+}
+
+// nullString maps the empty string to NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// nullTime maps the zero time to NULL. Times are stored in UTC,
+// so that the textual sqlite representation compares correctly.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
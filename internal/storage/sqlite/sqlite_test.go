@@ -0,0 +1,52 @@
+package sqlite_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/storage"
+	"github.com/zulerne/url-shortener/internal/storage/sqlite"
+	"github.com/zulerne/url-shortener/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.RunConformance(t, func(t *testing.T) storagetest.Storage {
+		s, err := sqlite.New(filepath.Join(t.TempDir(), "storage.db"))
+		require.NoError(t, err)
+
+		return s
+	})
+}
+
+// TestMigrateAutoincrement rebuilds the url table back and forth,
+// links and their clicks must survive it.
+func TestMigrateAutoincrement(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	s, err := sqlite.New(path)
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(t.Context(), storage.Link{Alias: "abc", URL: "https://google.com"})
+	require.NoError(t, err)
+	require.NoError(t, s.SaveClicks(t.Context(), []storage.Click{{Alias: "abc", At: time.Now()}}))
+
+	db, err := sqlite.Open(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := sqlite.NewMigrator(db)
+	require.NoError(t, err)
+
+	require.NoError(t, m.Down(t.Context()))
+	require.NoError(t, m.Up(t.Context()))
+
+	link, err := s.GetURL(t.Context(), "abc")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", link.URL)
+
+	stats, err := s.GetStats(t.Context(), "abc")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.Total)
+}
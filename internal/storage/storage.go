@@ -1,8 +1,133 @@
 package storage
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 var (
 	ErrAliasExists = fmt.Errorf("alias already exists")
 	ErrNotFound    = fmt.Errorf("url not found")
+	ErrExpired     = fmt.Errorf("url expired")
+	ErrExhausted   = fmt.Errorf("url click limit reached")
 )
+
+// Link is a short link as stored by a Storage implementation.
+type Link struct {
+	ID    int64
+	Alias string
+	URL   string
+	// ValidFrom is the moment the link starts redirecting, zero means on creation.
+	ValidFrom time.Time
+	// ExpiresAt is the moment the link stops redirecting, zero means never.
+	ExpiresAt time.Time
+	// UpdatedAt is the moment the destination or UTM tags were last changed, zero means never.
+	UpdatedAt time.Time
+	// CreatedAt is set by the storage on save. It is zero for links
+	// created before it was tracked.
+	CreatedAt time.Time
+	// CreatedBy is the user who created the link, empty if unknown.
+	CreatedBy string
+	// NormalizedURL deduplicates links with a generated alias: saving one whose
+	// NormalizedURL is already stored returns the stored link instead. Empty
+	// disables deduplication. Custom aliases ignore it and updates clear it.
+	NormalizedURL string
+	// RedirectType is the http status of redirects, like 301 or 307. Zero means the service default.
+	RedirectType int
+	// ForwardQuery merges the query of the redirect request into the destination,
+	// parameters of the destination win.
+	ForwardQuery bool
+	// Prefix makes the link redirect /{alias}/rest to URL with rest appended to its path.
+	Prefix bool
+	// UTM tags are added to the destination on redirect.
+	UTM UTM
+	// PasswordHash is the bcrypt hash of the password protecting the link, empty if unprotected.
+	PasswordHash string
+	// MaxClicks limits how many times the link redirects, zero means unlimited.
+	MaxClicks int64
+	// ClicksUsed counts the redirects of a link with MaxClicks, see ConsumeClick of the storages.
+	// It is read-only.
+	ClicksUsed int64
+	// Clicks is the number of recorded redirects. It is read-only.
+	Clicks int64
+}
+
+// UTM holds the utm_* query parameters of a link, empty ones are not set.
+type UTM struct {
+	Source   string
+	Medium   string
+	Campaign string
+	Term     string
+	Content  string
+}
+
+// SaveResult is the outcome of saving one link of a batch.
+type SaveResult struct {
+	// ID of the saved link, zero if Err is set.
+	ID int64
+	// Alias of the saved link, generated if the link had none.
+	Alias string
+	// Reused is set if an existing link with the same NormalizedURL was returned.
+	Reused bool
+	// Err is ErrAliasExists if the alias is taken, including by an earlier link of the same batch.
+	Err error
+}
+
+// MaxAliasAttempts bounds how many generated aliases are tried for one link.
+const MaxAliasAttempts = 5
+
+// AliasFunc generates the alias of the link with the given row id. attempt counts
+// the aliases of that link which turned out to be taken. When that happens the link
+// is given a new id, so aliases derived from the id change too. An empty alias
+// counts as taken, so an AliasFunc can skip aliases it does not want.
+type AliasFunc func(id int64, attempt int) string
+
+// Expired reports whether the link is expired at the given moment.
+func (l Link) Expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !now.Before(l.ExpiresAt)
+}
+
+// Pending reports whether the link is not valid yet at the given moment.
+func (l Link) Pending(now time.Time) bool {
+	return !l.ValidFrom.IsZero() && now.Before(l.ValidFrom)
+}
+
+// Exhausted reports whether the link used up its MaxClicks.
+func (l Link) Exhausted() bool {
+	return l.MaxClicks > 0 && l.ClicksUsed >= l.MaxClicks
+}
+
+// ListParams selects a page of links ordered by id, which follows creation order.
+type ListParams struct {
+	// Cursor is the id of the last link of the previous page, 0 starts from the beginning.
+	Cursor int64
+	Limit  int
+	// Desc lists the newest links first.
+	Desc bool
+	// AliasPrefix keeps links whose alias starts with it (case-sensitive).
+	AliasPrefix string
+	// URLContains keeps links whose destination contains it (case-sensitive).
+	URLContains string
+}
+
+// Click is a single redirect through a short link.
+type Click struct {
+	Alias     string
+	At        time.Time
+	Referrer  string
+	UserAgent string
+	RequestID string
+}
+
+// Stats aggregates clicks of a link.
+type Stats struct {
+	Total int64
+	// Daily holds click counts per UTC day, ordered by day.
+	Daily []DailyClicks
+}
+
+type DailyClicks struct {
+	// Day is formatted as 2006-01-02.
+	Day    string
+	Clicks int64
+}
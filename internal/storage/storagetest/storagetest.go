@@ -0,0 +1,1036 @@
+// Package storagetest provides a conformance test suite that every
+// handler.Storage implementation is expected to pass.
+package storagetest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zulerne/url-shortener/internal/analytics"
+	"github.com/zulerne/url-shortener/internal/janitor"
+	"github.com/zulerne/url-shortener/internal/lib/random"
+	"github.com/zulerne/url-shortener/internal/server/handler"
+	"github.com/zulerne/url-shortener/internal/storage"
+)
+
+// Storage is everything a backend has to implement.
+type Storage interface {
+	handler.Storage
+	janitor.Purger
+	analytics.Saver
+}
+
+// Factory returns a ready to use Storage. It is called once per test case,
+// implementations may register cleanup with t.Cleanup.
+type Factory func(t *testing.T) Storage
+
+// workers is the number of goroutines used by the concurrency cases.
+const workers = 50
+
+// RunConformance runs the whole suite against storages created by factory.
+// Aliases are random, so a factory may return a storage shared between cases.
+func RunConformance(t *testing.T, factory Factory) {
+	t.Helper()
+
+	cases := []struct {
+		name string
+		test func(t *testing.T, s Storage)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"SaveReturnsUniqueIDs", testSaveReturnsUniqueIDs},
+		{"SaveDuplicateAlias", testSaveDuplicateAlias},
+		{"SaveGenerated", testSaveGenerated},
+		{"SaveGeneratedTaken", testSaveGeneratedTaken},
+		{"SaveGeneratedExhausted", testSaveGeneratedExhausted},
+		{"SaveGeneratedDedup", testSaveGeneratedDedup},
+		{"SaveGeneratedAfterDelete", testSaveGeneratedAfterDelete},
+		{"SaveDedupIgnoresCustomAlias", testSaveDedupIgnoresCustomAlias},
+		{"UpdateClearsDedup", testUpdateClearsDedup},
+		{"SaveBatch", testSaveBatch},
+		{"SaveBatchGenerated", testSaveBatchGenerated},
+		{"SaveBatchDedup", testSaveBatchDedup},
+		{"SaveBatchCanceledContext", testSaveBatchCanceledContext},
+		{"GetNotFound", testGetNotFound},
+		{"GetURLLink", testGetURLLink},
+		{"GetLink", testGetLink},
+		{"GetLinkNotFound", testGetLinkNotFound},
+		{"Update", testUpdate},
+		{"UpdateNotFound", testUpdateNotFound},
+		{"ConsumeClick", testConsumeClick},
+		{"ConsumeClickUnlimited", testConsumeClickUnlimited},
+		{"ConsumeClickNotFound", testConsumeClickNotFound},
+		{"UpdateUTM", testUpdateUTM},
+		{"UpdateUTMNotFound", testUpdateUTMNotFound},
+		{"Delete", testDelete},
+		{"DeleteNotFound", testDeleteNotFound},
+		{"SaveAfterDelete", testSaveAfterDelete},
+		{"ConcurrentSaveSameAlias", testConcurrentSaveSameAlias},
+		{"ConcurrentSaveDistinctAliases", testConcurrentSaveDistinctAliases},
+		{"ConcurrentSaveGenerated", testConcurrentSaveGenerated},
+		{"ConcurrentSaveDedup", testConcurrentSaveDedup},
+		{"ConcurrentDeleteSameAlias", testConcurrentDeleteSameAlias},
+		{"ConcurrentConsumeClick", testConcurrentConsumeClick},
+		{"CanceledContext", testCanceledContext},
+		{"GetExpired", testGetExpired},
+		{"DeleteExpired", testDeleteExpired},
+		{"List", testList},
+		{"ListFilters", testListFilters},
+		{"ClickStats", testClickStats},
+		{"ClickStatsNotFound", testClickStatsNotFound},
+		{"ClicksOfDeletedURL", testClicksOfDeletedURL},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, factory(t))
+		})
+	}
+}
+
+func newAlias() string {
+	return random.Alias(12)
+}
+
+// getURL returns the destination of alias.
+func getURL(ctx context.Context, s Storage, alias string) (string, error) {
+	link, err := s.GetURL(ctx, alias)
+	return link.URL, err
+}
+
+// prefixAliases returns an AliasFunc generating prefix-id aliases.
+func prefixAliases(prefix string) storage.AliasFunc {
+	return func(id int64, attempt int) string {
+		return fmt.Sprintf("%s-%d", prefix, id)
+	}
+}
+
+func testSaveAndGet(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	id, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+	require.NoError(t, err)
+	require.NotZero(t, id)
+
+	url, err := getURL(t.Context(), s, alias)
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", url)
+}
+
+func testSaveReturnsUniqueIDs(t *testing.T, s Storage) {
+	first, err := s.SaveURL(t.Context(), storage.Link{Alias: newAlias(), URL: "https://google.com"})
+	require.NoError(t, err)
+
+	second, err := s.SaveURL(t.Context(), storage.Link{Alias: newAlias(), URL: "https://google.com"})
+	require.NoError(t, err)
+
+	require.NotEqual(t, first, second)
+}
+
+func testSaveDuplicateAlias(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://example.com"})
+	require.ErrorIs(t, err, storage.ErrAliasExists)
+
+	url, err := getURL(t.Context(), s, alias)
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", url, "duplicate save must not overwrite the url")
+}
+
+func testSaveBatch(t *testing.T, s Storage) {
+	taken := newAlias()
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: taken, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	first, second := newAlias(), newAlias()
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	results, err := s.SaveURLs(t.Context(), []storage.Link{
+		{Alias: first, URL: "https://example.com/1", ExpiresAt: expiresAt, CreatedBy: "admin"},
+		{Alias: taken, URL: "https://example.com/2"},
+		{Alias: second, URL: "https://example.com/3"},
+		{Alias: first, URL: "https://example.com/4"},
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	require.NoError(t, results[0].Err)
+	require.NotZero(t, results[0].ID)
+	require.Equal(t, first, results[0].Alias)
+	require.ErrorIs(t, results[1].Err, storage.ErrAliasExists)
+	require.NoError(t, results[2].Err)
+	require.NotZero(t, results[2].ID)
+	require.NotEqual(t, results[0].ID, results[2].ID)
+	require.ErrorIs(t, results[3].Err, storage.ErrAliasExists, "duplicate alias within the batch")
+
+	link, err := s.GetLink(t.Context(), first)
+	require.NoError(t, err)
+	require.Equal(t, results[0].ID, link.ID)
+	require.Equal(t, "https://example.com/1", link.URL)
+	require.Equal(t, "admin", link.CreatedBy)
+	require.True(t, expiresAt.Equal(link.ExpiresAt), "expires at %s, want %s", link.ExpiresAt, expiresAt)
+
+	url, err := getURL(t.Context(), s, taken)
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", url, "batch must not overwrite existing links")
+
+	url, err = getURL(t.Context(), s, second)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/3", url)
+}
+
+func testSaveBatchGenerated(t *testing.T, s Storage) {
+	prefix := newAlias()
+	taken := newAlias()
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: taken, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	custom := newAlias()
+	results, err := s.SaveURLs(t.Context(), []storage.Link{
+		{URL: "https://example.com/1"},
+		{Alias: custom, URL: "https://example.com/2"},
+		{URL: "https://example.com/3"},
+		{URL: "https://example.com/4"},
+	}, func(id int64, attempt int) string {
+		return fmt.Sprintf("%s-%d", prefix, id)
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+
+	for _, i := range []int{0, 2, 3} {
+		require.NoError(t, results[i].Err)
+		require.Equal(t, fmt.Sprintf("%s-%d", prefix, results[i].ID), results[i].Alias)
+
+		url, err := getURL(t.Context(), s, results[i].Alias)
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("https://example.com/%d", i+1), url)
+	}
+	require.NoError(t, results[1].Err)
+	require.Equal(t, custom, results[1].Alias)
+
+	// A link whose generated aliases are all taken fails alone
+	calls := 0
+	results, err = s.SaveURLs(t.Context(), []storage.Link{
+		{URL: "https://example.com/5"},
+		{URL: "https://example.com/6"},
+	}, func(id int64, attempt int) string {
+		calls++
+		if calls <= storage.MaxAliasAttempts {
+			return taken
+		}
+		return fmt.Sprintf("%s-%d", prefix, id)
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.ErrorIs(t, results[0].Err, storage.ErrAliasExists)
+	require.NoError(t, results[1].Err)
+
+	url, err := getURL(t.Context(), s, results[1].Alias)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com/6", url)
+}
+
+func testSaveBatchDedup(t *testing.T, s Storage) {
+	normalized := "https://example.com/" + newAlias()
+	aliasOf := prefixAliases(newAlias())
+
+	existing, _, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: normalized, NormalizedURL: normalized}, aliasOf)
+	require.NoError(t, err)
+
+	other := "https://example.com/" + newAlias()
+	results, err := s.SaveURLs(t.Context(), []storage.Link{
+		{URL: normalized, NormalizedURL: normalized},
+		{URL: other, NormalizedURL: other},
+		{URL: other, NormalizedURL: other},
+	}, aliasOf)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	require.NoError(t, results[0].Err)
+	require.True(t, results[0].Reused)
+	require.Equal(t, existing.Alias, results[0].Alias)
+
+	require.NoError(t, results[1].Err)
+	require.False(t, results[1].Reused)
+
+	require.NoError(t, results[2].Err)
+	require.True(t, results[2].Reused, "a link of the same batch must be reused")
+	require.Equal(t, results[1].Alias, results[2].Alias)
+	require.Equal(t, results[1].ID, results[2].ID)
+}
+
+func testSaveBatchCanceledContext(t *testing.T, s Storage) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	alias := newAlias()
+	_, err := s.SaveURLs(ctx, []storage.Link{{Alias: alias, URL: "https://google.com"}}, nil)
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = getURL(t.Context(), s, alias)
+	require.ErrorIs(t, err, storage.ErrNotFound, "canceled batch must not be saved")
+}
+
+func testSaveGenerated(t *testing.T, s Storage) {
+	prefix := newAlias()
+
+	link, created, err := s.SaveGeneratedURL(t.Context(), storage.Link{
+		URL:       "https://google.com",
+		CreatedBy: "admin",
+	}, func(id int64, attempt int) string {
+		return fmt.Sprintf("%s-%d-%d", prefix, id, attempt)
+	})
+	require.NoError(t, err)
+	require.True(t, created)
+	require.NotZero(t, link.ID)
+	require.Equal(t, fmt.Sprintf("%s-%d-0", prefix, link.ID), link.Alias, "alias must be generated from the id of the link")
+
+	saved, err := s.GetLink(t.Context(), link.Alias)
+	require.NoError(t, err)
+	require.Equal(t, link.ID, saved.ID)
+	require.Equal(t, "https://google.com", saved.URL)
+	require.Equal(t, "admin", saved.CreatedBy)
+}
+
+// Aliases generated from the id alone must not be handed out again once their link is
+// deleted, links shared before would redirect somewhere else.
+func testSaveGeneratedAfterDelete(t *testing.T, s Storage) {
+	aliases := prefixAliases(newAlias())
+
+	deleted, _, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: "https://google.com"}, aliases)
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURL(t.Context(), deleted.Alias))
+
+	link, _, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: "https://example.com"}, aliases)
+	require.NoError(t, err)
+	require.NotEqual(t, deleted.ID, link.ID, "ids of deleted links must not be reused")
+	require.NotEqual(t, deleted.Alias, link.Alias)
+
+	// Same for links moved to a new id by a taken alias
+	taken := newAlias()
+	_, err = s.SaveURL(t.Context(), storage.Link{Alias: taken, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	moved, _, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: "https://example.org"}, func(id int64, attempt int) string {
+		if attempt == 0 {
+			return taken
+		}
+		return aliases(id, attempt)
+	})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURL(t.Context(), moved.Alias))
+
+	link, _, err = s.SaveGeneratedURL(t.Context(), storage.Link{URL: "https://example.net"}, aliases)
+	require.NoError(t, err)
+	require.NotEqual(t, moved.ID, link.ID, "ids of deleted links must not be reused")
+	require.NotEqual(t, moved.Alias, link.Alias)
+}
+
+func testSaveGeneratedTaken(t *testing.T, s Storage) {
+	taken := newAlias()
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: taken, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	prefix := newAlias()
+	var ids []int64
+
+	link, _, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: "https://example.com"}, func(id int64, attempt int) string {
+		require.Equal(t, len(ids), attempt)
+		ids = append(ids, id)
+		switch attempt {
+		case 0:
+			return taken
+		case 1:
+			// Skipped alias
+			return ""
+		default:
+			return fmt.Sprintf("%s-%d", prefix, id)
+		}
+	})
+	require.NoError(t, err)
+	require.Len(t, ids, 3)
+	require.NotEqual(t, ids[0], ids[1], "a taken alias must move the link to a new id")
+	require.NotEqual(t, ids[1], ids[2], "a skipped alias must move the link to a new id")
+	require.Equal(t, ids[2], link.ID)
+	require.Equal(t, fmt.Sprintf("%s-%d", prefix, link.ID), link.Alias)
+
+	url, err := getURL(t.Context(), s, link.Alias)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", url)
+
+	url, err = getURL(t.Context(), s, taken)
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", url, "generated link must not overwrite the taken alias")
+}
+
+func testSaveGeneratedExhausted(t *testing.T, s Storage) {
+	taken := newAlias()
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: taken, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	attempts := 0
+	_, _, err = s.SaveGeneratedURL(t.Context(), storage.Link{URL: "https://example.com"}, func(int64, int) string {
+		attempts++
+		return taken
+	})
+	require.ErrorIs(t, err, storage.ErrAliasExists)
+	require.Equal(t, storage.MaxAliasAttempts, attempts)
+
+	// The failed link must not be left behind
+	alias := newAlias()
+	_, _, err = s.SaveGeneratedURL(t.Context(), storage.Link{URL: "https://example.com"}, func(int64, int) string {
+		return alias
+	})
+	require.NoError(t, err)
+}
+
+func testSaveGeneratedDedup(t *testing.T, s Storage) {
+	normalized := "https://example.com/" + newAlias()
+	aliasOf := prefixAliases(newAlias())
+
+	first, created, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: normalized, NormalizedURL: normalized}, aliasOf)
+	require.NoError(t, err)
+	require.True(t, created)
+
+	second, created, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: normalized + "?", NormalizedURL: normalized}, aliasOf)
+	require.NoError(t, err)
+	require.False(t, created, "a link with the same normalized url must be reused")
+	require.Equal(t, first.ID, second.ID)
+	require.Equal(t, first.Alias, second.Alias)
+	require.Equal(t, normalized, second.URL, "the reused link must keep its url")
+
+	// Links without a normalized url are never deduplicated
+	third, created, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: normalized}, aliasOf)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.NotEqual(t, first.Alias, third.Alias)
+}
+
+func testSaveDedupIgnoresCustomAlias(t *testing.T, s Storage) {
+	normalized := "https://example.com/" + newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: newAlias(), URL: normalized, NormalizedURL: normalized})
+	require.NoError(t, err)
+
+	_, created, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: normalized, NormalizedURL: normalized}, prefixAliases(newAlias()))
+	require.NoError(t, err)
+	require.True(t, created, "links with a custom alias must not be reused")
+}
+
+func testUpdateClearsDedup(t *testing.T, s Storage) {
+	normalized := "https://example.com/" + newAlias()
+	aliasOf := prefixAliases(newAlias())
+
+	link, _, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: normalized, NormalizedURL: normalized}, aliasOf)
+	require.NoError(t, err)
+
+	err = s.UpdateURL(t.Context(), link.Alias, "https://google.com")
+	require.NoError(t, err)
+
+	updated, err := s.GetLink(t.Context(), link.Alias)
+	require.NoError(t, err)
+	require.Empty(t, updated.NormalizedURL)
+
+	_, created, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: normalized, NormalizedURL: normalized}, aliasOf)
+	require.NoError(t, err)
+	require.True(t, created, "an updated link must not be reused for its old url")
+}
+
+func testGetURLLink(t *testing.T, s Storage) {
+	alias := newAlias()
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	validFrom := expiresAt.Add(-30 * time.Minute)
+
+	// Links which are not valid yet are returned, redirecting them is up to the caller
+	id, err := s.SaveURL(t.Context(), storage.Link{
+		Alias:        alias,
+		URL:          "https://google.com",
+		ValidFrom:    validFrom,
+		ExpiresAt:    expiresAt,
+		RedirectType: 301,
+		ForwardQuery: true,
+		PasswordHash: "hash",
+	})
+	require.NoError(t, err)
+
+	link, err := s.GetURL(t.Context(), alias)
+	require.NoError(t, err)
+	require.Equal(t, id, link.ID)
+	require.Equal(t, alias, link.Alias)
+	require.Equal(t, "https://google.com", link.URL)
+	require.Equal(t, 301, link.RedirectType)
+	require.True(t, link.ForwardQuery)
+	require.False(t, link.Prefix)
+	require.Equal(t, "hash", link.PasswordHash)
+	require.True(t, expiresAt.Equal(link.ExpiresAt), "expires at %s, want %s", link.ExpiresAt, expiresAt)
+	require.True(t, validFrom.Equal(link.ValidFrom), "valid from %s, want %s", link.ValidFrom, validFrom)
+
+	generated, _, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: "https://google.com", RedirectType: 308, Prefix: true}, prefixAliases(newAlias()))
+	require.NoError(t, err)
+
+	link, err = s.GetURL(t.Context(), generated.Alias)
+	require.NoError(t, err)
+	require.Equal(t, 308, link.RedirectType)
+	require.False(t, link.ForwardQuery)
+	require.True(t, link.Prefix)
+	require.Empty(t, link.PasswordHash)
+
+	results, err := s.SaveURLs(t.Context(), []storage.Link{{Alias: newAlias(), URL: "https://google.com", RedirectType: 302, ForwardQuery: true, Prefix: true}}, nil)
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+
+	link, err = s.GetLink(t.Context(), results[0].Alias)
+	require.NoError(t, err)
+	require.Equal(t, 302, link.RedirectType)
+	require.True(t, link.ForwardQuery)
+	require.True(t, link.Prefix)
+}
+
+func testGetNotFound(t *testing.T, s Storage) {
+	_, err := getURL(t.Context(), s, newAlias())
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testGetLink(t *testing.T, s Storage) {
+	alias := newAlias()
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	id, err := s.SaveURL(t.Context(), storage.Link{
+		Alias:     alias,
+		URL:       "https://google.com",
+		ExpiresAt: expiresAt,
+		CreatedBy: "admin",
+	})
+	require.NoError(t, err)
+
+	link, err := s.GetLink(t.Context(), alias)
+	require.NoError(t, err)
+	require.Equal(t, id, link.ID)
+	require.Equal(t, alias, link.Alias)
+	require.Equal(t, "https://google.com", link.URL)
+	require.Equal(t, "admin", link.CreatedBy)
+	require.True(t, expiresAt.Equal(link.ExpiresAt), "expires at %s, want %s", link.ExpiresAt, expiresAt)
+	require.WithinDuration(t, time.Now(), link.CreatedAt, time.Minute)
+	require.True(t, link.UpdatedAt.IsZero())
+	require.Zero(t, link.Clicks)
+
+	require.NoError(t, s.SaveClicks(t.Context(), []storage.Click{
+		{Alias: alias, At: time.Now()},
+		{Alias: alias, At: time.Now()},
+	}))
+	require.NoError(t, s.UpdateURL(t.Context(), alias, "https://example.com"))
+
+	link, err = s.GetLink(t.Context(), alias)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", link.URL)
+	require.Equal(t, int64(2), link.Clicks)
+	require.WithinDuration(t, time.Now(), link.UpdatedAt, time.Minute)
+}
+
+func testGetLinkNotFound(t *testing.T, s Storage) {
+	_, err := s.GetLink(t.Context(), newAlias())
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testUpdate(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.UpdateURL(t.Context(), alias, "https://example.com"))
+
+	url, err := getURL(t.Context(), s, alias)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", url)
+}
+
+func testUpdateNotFound(t *testing.T, s Storage) {
+	err := s.UpdateURL(t.Context(), newAlias(), "https://example.com")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testConsumeClick(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com", MaxClicks: 2})
+	require.NoError(t, err)
+
+	require.NoError(t, s.ConsumeClick(t.Context(), alias))
+
+	link, err := s.GetURL(t.Context(), alias)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, link.MaxClicks)
+	require.EqualValues(t, 1, link.ClicksUsed)
+
+	require.NoError(t, s.ConsumeClick(t.Context(), alias))
+	require.ErrorIs(t, s.ConsumeClick(t.Context(), alias), storage.ErrExhausted)
+
+	_, err = s.GetURL(t.Context(), alias)
+	require.ErrorIs(t, err, storage.ErrExhausted)
+
+	// Exhausted links can still be inspected
+	link, err = s.GetLink(t.Context(), alias)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, link.ClicksUsed)
+}
+
+func testConsumeClickUnlimited(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	for range 3 {
+		require.NoError(t, s.ConsumeClick(t.Context(), alias))
+	}
+
+	link, err := s.GetURL(t.Context(), alias)
+	require.NoError(t, err)
+	require.Zero(t, link.ClicksUsed)
+}
+
+func testConsumeClickNotFound(t *testing.T, s Storage) {
+	err := s.ConsumeClick(t.Context(), newAlias())
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testUpdateUTM(t *testing.T, s Storage) {
+	alias := newAlias()
+	utm := storage.UTM{Source: "newsletter", Medium: "email", Campaign: "spring", Term: "shoes", Content: "banner"}
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com", UTM: utm})
+	require.NoError(t, err)
+
+	link, err := s.GetURL(t.Context(), alias)
+	require.NoError(t, err)
+	require.Equal(t, utm, link.UTM)
+
+	utm = storage.UTM{Source: "blog"}
+	require.NoError(t, s.UpdateUTM(t.Context(), alias, utm))
+
+	link, err = s.GetLink(t.Context(), alias)
+	require.NoError(t, err)
+	require.Equal(t, utm, link.UTM)
+	require.Equal(t, "https://google.com", link.URL)
+	require.False(t, link.UpdatedAt.IsZero())
+
+	generated, _, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: "https://google.com", UTM: utm}, prefixAliases(newAlias()))
+	require.NoError(t, err)
+
+	link, err = s.GetLink(t.Context(), generated.Alias)
+	require.NoError(t, err)
+	require.Equal(t, utm, link.UTM)
+}
+
+func testUpdateUTMNotFound(t *testing.T, s Storage) {
+	err := s.UpdateUTM(t.Context(), newAlias(), storage.UTM{Source: "blog"})
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testDelete(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteURL(t.Context(), alias))
+
+	_, err = getURL(t.Context(), s, alias)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testDeleteNotFound(t *testing.T, s Storage) {
+	require.ErrorIs(t, s.DeleteURL(t.Context(), newAlias()), storage.ErrNotFound)
+}
+
+func testSaveAfterDelete(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteURL(t.Context(), alias))
+
+	_, err = s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://example.com"})
+	require.NoError(t, err)
+
+	url, err := getURL(t.Context(), s, alias)
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", url)
+}
+
+func testConcurrentSaveSameAlias(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	errs := runConcurrently(func(int) error {
+		_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+		return err
+	})
+
+	saved := 0
+	for _, err := range errs {
+		if err == nil {
+			saved++
+			continue
+		}
+		require.ErrorIs(t, err, storage.ErrAliasExists)
+	}
+	require.Equal(t, 1, saved, "exactly one save must win the race")
+}
+
+func testConcurrentSaveDistinctAliases(t *testing.T, s Storage) {
+	aliases := make([]string, workers)
+	for i := range aliases {
+		aliases[i] = newAlias()
+	}
+
+	var (
+		mu  sync.Mutex
+		ids = make(map[int64]bool, workers)
+	)
+
+	errs := runConcurrently(func(i int) error {
+		id, err := s.SaveURL(t.Context(), storage.Link{Alias: aliases[i], URL: "https://google.com"})
+
+		mu.Lock()
+		defer mu.Unlock()
+		ids[id] = true
+
+		return err
+	})
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.Len(t, ids, workers, "every save must get its own id")
+
+	for _, alias := range aliases {
+		_, err := getURL(t.Context(), s, alias)
+		require.NoError(t, err)
+	}
+}
+
+func testConcurrentSaveGenerated(t *testing.T, s Storage) {
+	prefix := newAlias()
+	aliasOf := func(id int64, attempt int) string {
+		return fmt.Sprintf("%s-%d", prefix, id)
+	}
+
+	var (
+		mu      sync.Mutex
+		aliases = make(map[string]bool, workers)
+	)
+
+	errs := runConcurrently(func(int) error {
+		link, _, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: "https://google.com"}, aliasOf)
+
+		mu.Lock()
+		defer mu.Unlock()
+		aliases[link.Alias] = true
+
+		return err
+	})
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.Len(t, aliases, workers, "every save must get its own alias")
+}
+
+func testConcurrentSaveDedup(t *testing.T, s Storage) {
+	normalized := "https://example.com/" + newAlias()
+	aliasOf := prefixAliases(newAlias())
+
+	var (
+		mu      sync.Mutex
+		aliases = make(map[string]bool)
+		created int
+	)
+
+	errs := runConcurrently(func(int) error {
+		link, ok, err := s.SaveGeneratedURL(t.Context(), storage.Link{URL: normalized, NormalizedURL: normalized}, aliasOf)
+
+		mu.Lock()
+		defer mu.Unlock()
+		aliases[link.Alias] = true
+		if ok {
+			created++
+		}
+
+		return err
+	})
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 1, created, "exactly one save must create the link")
+	require.Len(t, aliases, 1, "every save must return the same alias")
+}
+
+func testConcurrentDeleteSameAlias(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	errs := runConcurrently(func(int) error {
+		return s.DeleteURL(t.Context(), alias)
+	})
+
+	deleted := 0
+	for _, err := range errs {
+		if err == nil {
+			deleted++
+			continue
+		}
+		require.ErrorIs(t, err, storage.ErrNotFound)
+	}
+	require.Equal(t, 1, deleted, "exactly one delete must win the race")
+}
+
+func testConcurrentConsumeClick(t *testing.T, s Storage) {
+	const maxClicks = 10
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com", MaxClicks: maxClicks})
+	require.NoError(t, err)
+
+	errs := runConcurrently(func(int) error {
+		return s.ConsumeClick(t.Context(), alias)
+	})
+
+	consumed := 0
+	for _, err := range errs {
+		if err == nil {
+			consumed++
+			continue
+		}
+		require.ErrorIs(t, err, storage.ErrExhausted)
+	}
+	require.Equal(t, maxClicks, consumed, "concurrent clicks must not exceed the limit")
+}
+
+// runConcurrently calls fn from workers goroutines released at the same time
+// and returns their errors indexed by worker.
+func runConcurrently(fn func(i int) error) []error {
+	var (
+		wg    sync.WaitGroup
+		start = make(chan struct{})
+		errs  = make([]error, workers)
+	)
+
+	for i := range workers {
+		wg.Go(func() {
+			<-start
+			errs[i] = fn(i)
+		})
+	}
+	close(start)
+	wg.Wait()
+
+	return errs
+}
+
+func testCanceledContext(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err = s.SaveURL(ctx, storage.Link{Alias: newAlias(), URL: "https://google.com"})
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = getURL(ctx, s, alias)
+	require.ErrorIs(t, err, context.Canceled)
+
+	err = s.DeleteURL(ctx, alias)
+	require.ErrorIs(t, err, context.Canceled)
+
+	_, err = getURL(t.Context(), s, alias)
+	require.NoError(t, err, "canceled delete must not remove the url")
+}
+
+func testGetExpired(t *testing.T, s Storage) {
+	expired := newAlias()
+	live := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{
+		Alias:     expired,
+		URL:       "https://google.com",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+	require.NoError(t, err)
+
+	_, err = s.SaveURL(t.Context(), storage.Link{
+		Alias:     live,
+		URL:       "https://google.com",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	_, err = getURL(t.Context(), s, expired)
+	require.ErrorIs(t, err, storage.ErrExpired)
+
+	url, err := getURL(t.Context(), s, live)
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", url)
+}
+
+func testDeleteExpired(t *testing.T, s Storage) {
+	now := time.Now()
+	expired := newAlias()
+	live := newAlias()
+	permanent := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: expired, URL: "https://google.com", ExpiresAt: now.Add(-time.Second)})
+	require.NoError(t, err)
+	_, err = s.SaveURL(t.Context(), storage.Link{Alias: live, URL: "https://google.com", ExpiresAt: now.Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = s.SaveURL(t.Context(), storage.Link{Alias: permanent, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	deleted, err := s.DeleteExpiredURLs(t.Context(), now)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, deleted, int64(1))
+
+	_, err = getURL(t.Context(), s, expired)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = getURL(t.Context(), s, live)
+	require.NoError(t, err)
+
+	_, err = getURL(t.Context(), s, permanent)
+	require.NoError(t, err)
+}
+
+func testList(t *testing.T, s Storage) {
+	prefix := newAlias()
+
+	var aliases []string
+	for i := range 5 {
+		alias := fmt.Sprintf("%s-%d", prefix, i)
+		_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+		require.NoError(t, err)
+		aliases = append(aliases, alias)
+	}
+
+	collect := func(desc bool) []string {
+		var (
+			got    []string
+			cursor int64
+		)
+		for {
+			links, err := s.ListURLs(t.Context(), storage.ListParams{
+				Cursor:      cursor,
+				Limit:       2,
+				Desc:        desc,
+				AliasPrefix: prefix,
+			})
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(links), 2)
+
+			if len(links) == 0 {
+				return got
+			}
+			for _, link := range links {
+				require.Equal(t, "https://google.com", link.URL)
+				require.False(t, link.CreatedAt.IsZero())
+				got = append(got, link.Alias)
+			}
+			cursor = links[len(links)-1].ID
+		}
+	}
+
+	require.Equal(t, aliases, collect(false))
+	require.Equal(t, []string{aliases[4], aliases[3], aliases[2], aliases[1], aliases[0]}, collect(true))
+}
+
+func testListFilters(t *testing.T, s Storage) {
+	prefix := newAlias()
+	marker := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: prefix + "-a", URL: "https://google.com/" + marker})
+	require.NoError(t, err)
+	_, err = s.SaveURL(t.Context(), storage.Link{Alias: prefix + "-b", URL: "https://example.com"})
+	require.NoError(t, err)
+	_, err = s.SaveURL(t.Context(), storage.Link{Alias: newAlias(), URL: "https://example.com/" + marker})
+	require.NoError(t, err)
+
+	links, err := s.ListURLs(t.Context(), storage.ListParams{Limit: 10, AliasPrefix: prefix})
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+
+	links, err = s.ListURLs(t.Context(), storage.ListParams{Limit: 10, URLContains: marker})
+	require.NoError(t, err)
+	require.Len(t, links, 2)
+
+	links, err = s.ListURLs(t.Context(), storage.ListParams{Limit: 10, AliasPrefix: prefix, URLContains: marker})
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	require.Equal(t, prefix+"-a", links[0].Alias)
+
+	links, err = s.ListURLs(t.Context(), storage.ListParams{Limit: 10, AliasPrefix: strings.ToUpper(prefix) + "?"})
+	require.NoError(t, err)
+	require.Empty(t, links)
+}
+
+func testClickStats(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+	require.NoError(t, err)
+
+	stats, err := s.GetStats(t.Context(), alias)
+	require.NoError(t, err)
+	require.Zero(t, stats.Total)
+	require.Empty(t, stats.Daily)
+
+	day := time.Date(2025, time.March, 1, 23, 30, 0, 0, time.UTC)
+	err = s.SaveClicks(t.Context(), []storage.Click{
+		{Alias: alias, At: day, Referrer: "https://example.com", UserAgent: "test", RequestID: "1"},
+		{Alias: alias, At: day.Add(10 * time.Minute)},
+		{Alias: alias, At: day.Add(time.Hour)},
+		{Alias: newAlias(), At: day},
+	})
+	require.NoError(t, err)
+
+	stats, err = s.GetStats(t.Context(), alias)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.Total)
+	require.Equal(t, []storage.DailyClicks{
+		{Day: "2025-03-01", Clicks: 2},
+		{Day: "2025-03-02", Clicks: 1},
+	}, stats.Daily)
+}
+
+func testClickStatsNotFound(t *testing.T, s Storage) {
+	_, err := s.GetStats(t.Context(), newAlias())
+	require.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func testClicksOfDeletedURL(t *testing.T, s Storage) {
+	alias := newAlias()
+
+	_, err := s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://google.com"})
+	require.NoError(t, err)
+	require.NoError(t, s.SaveClicks(t.Context(), []storage.Click{{Alias: alias, At: time.Now()}}))
+	require.NoError(t, s.DeleteURL(t.Context(), alias))
+
+	_, err = s.SaveURL(t.Context(), storage.Link{Alias: alias, URL: "https://example.com"})
+	require.NoError(t, err)
+
+	stats, err := s.GetStats(t.Context(), alias)
+	require.NoError(t, err)
+	require.Zero(t, stats.Total, "clicks of a deleted url must not be inherited")
+}
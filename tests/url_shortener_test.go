@@ -1,11 +1,14 @@
 package tests
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"strings"
+	"sync"
 	"syscall"
 	"testing"
 	"time"
@@ -17,7 +20,8 @@ import (
 )
 
 const (
-	host = "localhost:8081"
+	host          = "localhost:8081"
+	blocklistFile = "./blocklist_test.txt"
 )
 
 func TestMain(m *testing.M) {
@@ -29,8 +33,17 @@ func TestMain(m *testing.M) {
 	os.Setenv("HTTP_SHUTDOWN_TIMEOUT", "5s")
 	os.Setenv("HTTP_USER", "admin")
 	os.Setenv("HTTP_PASSWORD", "admin")
+	os.Setenv("DEDUP_URLS", "true")
+	os.Setenv("STRIP_QUERY_PARAMS", "utm_*,fbclid")
+	os.Setenv("DOMAIN_BLOCKLIST_FILE", blocklistFile)
+	os.Setenv("DOMAIN_LIST_RELOAD_INTERVAL", "100ms")
 
-	cmd := exec.Command("go", "run", "../cmd/url-shortener/main.go")
+	if err := os.WriteFile(blocklistFile, []byte("*.evil.example\n"), 0o644); err != nil {
+		fmt.Printf("Failed to write blocklist: %v\n", err)
+		os.Exit(1)
+	}
+
+	cmd := exec.Command("go", "run", "../cmd/url-shortener")
 	cmd.Env = os.Environ()
 	cmd.Stdout = nil
 	cmd.Stderr = nil
@@ -63,6 +76,7 @@ func TestMain(m *testing.M) {
 	syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
 	cmd.Wait()
 	os.Remove("./storage_test.db")
+	os.Remove(blocklistFile)
 
 	os.Exit(code)
 }
@@ -168,6 +182,707 @@ func TestCreateAndRedirectURL(t *testing.T) {
 	}
 }
 
+func TestListURLs(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	prefix := random.Alias(10)
+	for i := range 3 {
+		e.POST("/url").
+			WithJSON(map[string]string{
+				"url":   gofakeit.URL(),
+				"alias": fmt.Sprintf("%s%d", prefix, i),
+			}).
+			WithBasicAuth("admin", "admin").
+			Expect().
+			Status(http.StatusOK)
+	}
+
+	page := e.GET("/url").
+		WithQuery("alias_prefix", prefix).
+		WithQuery("limit", 2).
+		WithQuery("order", "asc").
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	page.Value("urls").Array().Length().IsEqual(2)
+	page.Value("urls").Array().Value(0).Object().Value("alias").IsEqual(prefix + "0")
+	cursor := page.Value("next_cursor").String().NotEmpty().Raw()
+
+	page = e.GET("/url").
+		WithQuery("alias_prefix", prefix).
+		WithQuery("limit", 2).
+		WithQuery("order", "asc").
+		WithQuery("cursor", cursor).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	page.Value("urls").Array().Length().IsEqual(1)
+	page.Value("urls").Array().Value(0).Object().Value("alias").IsEqual(prefix + "2")
+	page.NotContainsKey("next_cursor")
+}
+
+func TestUpdateURL(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+	newURL := gofakeit.URL()
+
+	e.POST("/url").
+		WithJSON(map[string]string{
+			"url":   gofakeit.URL(),
+			"alias": alias,
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	e.PATCH("/url/"+alias).
+		WithJSON(map[string]string{
+			"url": newURL,
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	testRedirect(t, alias, newURL)
+
+	e.PATCH("/url/"+random.Alias(10)).
+		WithJSON(map[string]string{
+			"url": newURL,
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestDeleteURL(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+
+	e.POST("/url").
+		WithJSON(map[string]string{
+			"url":   gofakeit.URL(),
+			"alias": alias,
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	e.DELETE("/url/"+alias).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	e.DELETE("/url/"+alias).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusNotFound)
+
+	e.GET("/" + alias).
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestExpiredURL(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+
+	e.POST("/url").
+		WithJSON(map[string]string{
+			"url":   gofakeit.URL(),
+			"alias": alias,
+			"ttl":   "1s",
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ContainsKey("expires_at")
+
+	time.Sleep(1100 * time.Millisecond)
+
+	e.GET("/" + alias).
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusGone)
+}
+
+func TestURLStats(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+	urlToRedirect := gofakeit.URL()
+
+	e.POST("/url").
+		WithJSON(map[string]string{
+			"url":   urlToRedirect,
+			"alias": alias,
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	testRedirect(t, alias, urlToRedirect)
+	testRedirect(t, alias, urlToRedirect)
+
+	// Clicks are written asynchronously
+	require.Eventually(t, func() bool {
+		req, err := http.NewRequest(http.MethodGet, u.String()+"/url/"+alias+"/stats", nil)
+		if err != nil {
+			return false
+		}
+		req.SetBasicAuth("admin", "admin")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+
+		var stats struct {
+			Total int64 `json:"total"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+			return false
+		}
+
+		return stats.Total == 2
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestURLInfo(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+	urlToRedirect := gofakeit.URL()
+
+	e.POST("/url").
+		WithJSON(map[string]string{
+			"url":   urlToRedirect,
+			"alias": alias,
+			"ttl":   "1h",
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	info := e.GET("/url/"+alias+"/info").
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+
+	info.Value("alias").String().IsEqual(alias)
+	info.Value("url").String().IsEqual(urlToRedirect)
+	info.Value("created_by").String().IsEqual("admin")
+	info.Value("clicks").Number().IsEqual(0)
+	info.ContainsKey("created_at")
+	info.ContainsKey("expires_at")
+
+	e.GET("/url/"+random.Alias(10)+"/info").
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestBatchCreate(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+	urlToRedirect := gofakeit.URL()
+
+	results := e.POST("/url/batch").
+		WithJSON([]map[string]string{
+			{"url": urlToRedirect, "alias": alias},
+			{"url": gofakeit.URL(), "alias": alias},
+			{"url": "invalid_url"},
+			{"url": gofakeit.URL()},
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("results").Array()
+
+	results.Length().IsEqual(4)
+	results.Value(0).Object().Value("alias").String().IsEqual(alias)
+	results.Value(1).Object().Value("error").String().IsEqual("alias already exists")
+	results.Value(2).Object().Value("error").String().IsEqual("'URL' is not a valid url")
+	results.Value(3).Object().Value("alias").String().NotEmpty()
+
+	testRedirect(t, alias, urlToRedirect)
+}
+
+func TestDedupURL(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	urlToRedirect := "https://example.com/" + random.Alias(10)
+
+	first := e.POST("/url").
+		WithJSON(map[string]string{"url": urlToRedirect}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	first.Value("reused").Boolean().IsFalse()
+	alias := first.Value("alias").String().Raw()
+
+	second := e.POST("/url").
+		WithJSON(map[string]string{"url": "HTTPS://EXAMPLE.COM:443" + urlToRedirect[len("https://example.com"):]}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	second.Value("reused").Boolean().IsTrue()
+	second.Value("alias").String().IsEqual(alias)
+
+	// Expiring links are always new
+	e.POST("/url").
+		WithJSON(map[string]string{"url": urlToRedirect, "ttl": "1h"}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("alias").String().NotEqual(alias)
+
+	testRedirect(t, alias, urlToRedirect)
+}
+
+func TestCanonicalURL(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+
+	e.POST("/url").
+		WithJSON(map[string]string{
+			"url":   "https://Example.com:443/a/../b?z=2&utm_source=x&fbclid=y&a=1",
+			"alias": alias,
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	testRedirect(t, alias, "https://example.com/b?a=1&z=2")
+}
+
+func TestBlockedDestination(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	e.POST("/url").
+		WithJSON(map[string]string{"url": "javascript:alert(1)"}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.POST("/url").
+		WithJSON(map[string]string{"url": "https://www.evil.example/login"}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		Value("error").String().IsEqual("'URL' domain is not allowed")
+
+	// Domains blocked later stop redirecting
+	alias := random.Alias(10)
+	domain := strings.ToLower(random.Alias(10)) + ".example"
+
+	e.POST("/url").
+		WithJSON(map[string]string{"url": "https://" + domain + "/", "alias": alias}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	f, err := os.OpenFile(blocklistFile, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(domain + "\n")
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	require.Eventually(t, func() bool {
+		resp, err := client.Get(u.String() + "/" + alias)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+
+		return resp.StatusCode == http.StatusForbidden
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+func TestShortLinkChain(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+	urlToRedirect := gofakeit.URL()
+
+	e.POST("/url").
+		WithJSON(map[string]string{"url": urlToRedirect, "alias": alias}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	// Links to short links are flattened
+	chained := random.Alias(10)
+	e.POST("/url").
+		WithJSON(map[string]string{"url": "http://" + host + "/" + alias, "alias": chained}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	testRedirect(t, chained, urlToRedirect)
+
+	loop := random.Alias(10)
+	e.POST("/url").
+		WithJSON(map[string]string{"url": "http://" + host + "/" + loop, "alias": loop}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().
+		Value("error").String().IsEqual("'URL' is a redirect loop")
+}
+
+func TestRedirectType(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+	urlToRedirect := gofakeit.URL()
+
+	e.POST("/url").
+		WithJSON(map[string]any{
+			"url":           urlToRedirect,
+			"alias":         alias,
+			"redirect_type": http.StatusMovedPermanently,
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	resp := e.GET("/" + alias).
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusMovedPermanently)
+	resp.Header("Location").IsEqual(urlToRedirect)
+	resp.Header("Cache-Control").IsEqual("public, max-age=86400")
+
+	e.GET("/url/"+alias+"/info").
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("redirect_type").Number().IsEqual(http.StatusMovedPermanently)
+}
+
+func TestPassthrough(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+
+	e.POST("/url").
+		WithJSON(map[string]any{
+			"url":           "https://example.com/docs?lang=en",
+			"alias":         alias,
+			"forward_query": true,
+			"prefix":        true,
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET("/"+alias+"/guide/intro").
+		WithQuery("ref", "newsletter").
+		WithQuery("lang", "de").
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusTemporaryRedirect).
+		Header("Location").IsEqual("https://example.com/docs/guide/intro?lang=en&ref=newsletter")
+
+	info := e.GET("/url/"+alias+"/info").
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	info.Value("forward_query").Boolean().IsTrue()
+	info.Value("prefix").Boolean().IsTrue()
+
+	// Links without prefix have no sub-paths
+	plain := random.Alias(10)
+	e.POST("/url").
+		WithJSON(map[string]any{
+			"url":   "https://example.com/docs",
+			"alias": plain,
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET("/" + plain + "/guide").
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusNotFound)
+}
+
+func TestUTM(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+
+	// utm_* parameters of the url itself are stripped, see STRIP_QUERY_PARAMS
+	e.POST("/url").
+		WithJSON(map[string]any{
+			"url":   "https://example.com/shop?utm_source=old",
+			"alias": alias,
+			"utm":   map[string]string{"source": "newsletter", "campaign": "spring"},
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET("/" + alias).
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusTemporaryRedirect).
+		Header("Location").IsEqual("https://example.com/shop?utm_campaign=spring&utm_source=newsletter")
+
+	e.PUT("/url/"+alias+"/utm").
+		WithJSON(map[string]string{"source": "blog"}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET("/" + alias).
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusTemporaryRedirect).
+		Header("Location").IsEqual("https://example.com/shop?utm_source=blog")
+
+	e.GET("/url/"+alias+"/info").
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		Value("utm").Object().IsEqual(map[string]string{"source": "blog"})
+}
+
+func TestProtectedLink(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	alias := random.Alias(10)
+
+	e.POST("/url").
+		WithJSON(map[string]any{
+			"url":      "https://example.com/secret",
+			"alias":    alias,
+			"password": "hunter2",
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET("/" + alias).
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusOK).
+		ContentType("text/html").
+		Body().Contains(`name="password"`)
+
+	e.POST("/"+alias).
+		WithFormField("password", "wrong").
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusForbidden)
+
+	e.POST("/"+alias).
+		WithFormField("password", "hunter2").
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusSeeOther).
+		Header("Location").IsEqual("https://example.com/secret")
+
+	info := e.GET("/url/"+alias+"/info").
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	info.Value("protected").Boolean().IsTrue()
+	info.NotContainsKey("password")
+	info.NotContainsKey("password_hash")
+}
+
+func TestMaxClicks(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	const maxClicks = 3
+	alias := random.Alias(10)
+
+	e.POST("/url").
+		WithJSON(map[string]any{
+			"url":        "https://example.com/download",
+			"alias":      alias,
+			"max_clicks": maxClicks,
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	// Concurrent clicks never exceed the limit
+	codes := make([]int, 10)
+	var wg sync.WaitGroup
+	for i := range codes {
+		wg.Go(func() {
+			codes[i] = e.GET("/" + alias).
+				WithRedirectPolicy(httpexpect.DontFollowRedirects).
+				Expect().
+				Raw().StatusCode
+		})
+	}
+	wg.Wait()
+
+	redirected := 0
+	for _, code := range codes {
+		if code == http.StatusTemporaryRedirect {
+			redirected++
+			continue
+		}
+		require.Equal(t, http.StatusGone, code)
+	}
+	require.Equal(t, maxClicks, redirected)
+
+	info := e.GET("/url/"+alias+"/info").
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	info.Value("max_clicks").Number().IsEqual(maxClicks)
+	info.Value("clicks_left").Number().IsEqual(0)
+}
+
+func TestValidFrom(t *testing.T) {
+	u := url.URL{
+		Scheme: "http",
+		Host:   host,
+	}
+	e := httpexpect.Default(t, u.String())
+
+	scheduled := random.Alias(10)
+	validFrom := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	e.POST("/url").
+		WithJSON(map[string]any{
+			"url":        "https://example.com/launch",
+			"alias":      scheduled,
+			"valid_from": validFrom,
+			"ttl":        "24h",
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	e.GET("/" + scheduled).
+		WithRedirectPolicy(httpexpect.DontFollowRedirects).
+		Expect().
+		Status(http.StatusNotFound).
+		Header("Cache-Control").IsEqual("no-store")
+
+	info := e.GET("/url/"+scheduled+"/info").
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	info.Value("valid_from").String().AsDateTime(time.RFC3339).IsEqual(validFrom)
+	info.Value("expires_at").String().AsDateTime(time.RFC3339).IsEqual(validFrom.Add(24 * time.Hour))
+
+	active := random.Alias(10)
+
+	e.POST("/url").
+		WithJSON(map[string]any{
+			"url":        "https://example.com/launch",
+			"alias":      active,
+			"valid_from": time.Now().Add(-time.Minute),
+		}).
+		WithBasicAuth("admin", "admin").
+		Expect().
+		Status(http.StatusOK)
+
+	testRedirect(t, active, "https://example.com/launch")
+}
+
 func testRedirect(t *testing.T, alias string, urlToRedirect string) {
 	u := url.URL{
 		Scheme: "http",